@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
+)
+
+type addressListCollector struct {
+	lists     []string
+	countDesc *prometheus.Desc
+
+	// names of the lists seen per device and IP version, kept so a list
+	// which becomes empty is reported with 0 instead of disappearing
+	mu   sync.Mutex
+	seen map[string]map[string]bool
+}
+
+func newAddressListCollector(cfg config.AddressList) routerOSCollector {
+	c := &addressListCollector{
+		lists: cfg.Lists,
+		seen:  make(map[string]map[string]bool),
+	}
+	c.init()
+	return c
+}
+
+func (c *addressListCollector) init() {
+	labelNames := []string{"name", "address", "ip_version", "list", "dynamic"}
+	c.countDesc = description("address_list", "entries", "number of entries in the firewall address list", labelNames)
+}
+
+func (c *addressListCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.countDesc
+}
+
+func (c *addressListCollector) collect(ctx *collectorContext) error {
+	err := c.collectForIPVersion("4", "ip", ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.collectForIPVersion("6", "ipv6", ctx)
+}
+
+func (c *addressListCollector) collectForIPVersion(ipVersion, topic string, ctx *collectorContext) error {
+	referenced, err := c.fetchReferencedLists(ipVersion, topic, ctx)
+	if err != nil {
+		return err
+	}
+
+	lists := c.remember(ctx.device.Name+"/"+ipVersion, append(referenced, c.lists...))
+
+	for _, l := range lists {
+		for _, dynamic := range []string{"true", "false"} {
+			err := c.collectCount(ipVersion, topic, l, dynamic, ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fetchReferencedLists returns the address lists used by firewall rules.
+// Reading the entries themselves would transfer every entry of every list.
+func (c *addressListCollector) fetchReferencedLists(ipVersion, topic string, ctx *collectorContext) ([]string, error) {
+	lists := []string{}
+	for _, table := range firewallTables {
+		reply, err := ctx.client.Run(fmt.Sprintf("/%s/firewall/%s/print", topic, table), "=.proplist=src-address-list,dst-address-list")
+		if err != nil {
+			if err := c.handleError(ipVersion, err, ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, re := range reply.Re {
+			for _, p := range []string{"src-address-list", "dst-address-list"} {
+				// negated references look like !blocklist
+				if l := strings.TrimPrefix(re.Map[p], "!"); l != "" {
+					lists = append(lists, l)
+				}
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+// remember adds the lists to the ones seen before and returns all of them
+func (c *addressListCollector) remember(key string, lists []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen, ok := c.seen[key]
+	if !ok {
+		seen = make(map[string]bool)
+		c.seen[key] = seen
+	}
+	for _, l := range lists {
+		seen[l] = true
+	}
+
+	res := make([]string, 0, len(seen))
+	for l := range seen {
+		res = append(res, l)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func (c *addressListCollector) collectCount(ipVersion, topic, list, dynamic string, ctx *collectorContext) error {
+	reply, err := ctx.client.Run(fmt.Sprintf("/%s/firewall/address-list/print", topic), "?list="+list, "?dynamic="+dynamic, "=count-only=")
+	if err != nil {
+		return c.handleError(ipVersion, err, ctx)
+	}
+	if reply.Done.Map["ret"] == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(reply.Done.Map["ret"], 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"ip_version": ipVersion,
+			"list":       list,
+			"device":     ctx.device.Name,
+			"error":      err,
+		}).Error("error parsing address list metrics")
+		return err
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.countDesc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, ipVersion, list, dynamic)
+	return nil
+}
+
+// handleError ignores errors returned by the router, e.g. for a disabled
+// ipv6 package or IPv6 NAT before RouterOS 7
+func (c *addressListCollector) handleError(ipVersion string, err error, ctx *collectorContext) error {
+	if _, ok := err.(*routeros.DeviceError); ok {
+		log.WithFields(log.Fields{
+			"ip_version": ipVersion,
+			"device":     ctx.device.Name,
+			"error":      err,
+		}).Debug("address lists not available")
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"ip_version": ipVersion,
+		"device":     ctx.device.Name,
+		"error":      err,
+	}).Error("error fetching address list metrics")
+	return err
+}
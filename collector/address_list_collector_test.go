@@ -0,0 +1,19 @@
+package collector
+
+import (
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressListCollectorRemember(t *testing.T) {
+	c := newAddressListCollector(config.AddressList{}).(*addressListCollector)
+
+	assert.Equal(t, []string{"blocklist", "fail2ban"}, c.remember("router1/4", []string{"fail2ban", "blocklist", "fail2ban"}))
+
+	// lists no longer referenced are still reported
+	assert.Equal(t, []string{"blocklist", "fail2ban", "trusted"}, c.remember("router1/4", []string{"trusted"}))
+	assert.Equal(t, []string{}, c.remember("router1/6", nil))
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type bfdCollector struct {
+	props        []string
+	metricProps  []string
+	descriptions map[string]*prometheus.Desc
+}
+
+func newBFDCollector() routerOSCollector {
+	c := &bfdCollector{}
+	c.init()
+	return c
+}
+
+func (c *bfdCollector) init() {
+	c.metricProps = []string{"state", "state-changes", "uptime", "actual-tx-interval", "desired-tx-interval", "required-min-rx", "remote-min-rx", "multiplier", "packets-rx", "packets-tx"}
+	// v6 names the remote address of a neighbor "address"
+	c.props = append([]string{"remote-address", "address", "interface"}, c.metricProps...)
+
+	const prefix = "bfd_session"
+	labelNames := []string{"name", "address", "remote_address", "vrf", "interface"}
+
+	c.descriptions = make(map[string]*prometheus.Desc)
+	c.descriptions["state"] = description(prefix, "up", "BFD session is up (up = 1)", labelNames)
+	c.descriptions["state-changes"] = description(prefix, "state_changes", "number of BFD session state changes", labelNames)
+	c.descriptions["uptime"] = description(prefix, "uptime_seconds", "time since the BFD session came up", labelNames)
+	c.descriptions["actual-tx-interval"] = description(prefix, "tx_interval_seconds", "interval BFD packets are sent at", labelNames)
+	c.descriptions["desired-tx-interval"] = description(prefix, "desired_tx_interval_seconds", "configured minimum interval BFD packets are sent at", labelNames)
+	c.descriptions["required-min-rx"] = description(prefix, "rx_interval_seconds", "minimum interval BFD packets are accepted at", labelNames)
+	c.descriptions["remote-min-rx"] = description(prefix, "remote_rx_interval_seconds", "minimum interval the peer accepts BFD packets at", labelNames)
+	c.descriptions["multiplier"] = description(prefix, "multiplier", "number of missed BFD packets after which the session goes down", labelNames)
+	c.descriptions["packets-rx"] = description(prefix, "packets_received", "number of BFD packets received", labelNames)
+	c.descriptions["packets-tx"] = description(prefix, "packets_sent", "number of BFD packets sent", labelNames)
+}
+
+func (c *bfdCollector) requires() requirements {
+	return requirements{minVersion: 6, v6Packages: []string{"routing"}}
+}
+
+func (c *bfdCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *bfdCollector) collect(ctx *collectorContext) error {
+	path := "/routing/bfd/neighbor/print"
+	if ctx.caps.v7() {
+		path = "/routing/bfd/session/print"
+	}
+
+	reply, err := ctx.client.Run(path, "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching bfd metrics")
+		return err
+	}
+
+	for _, re := range reply.Re {
+		c.collectForSession(re, ctx)
+	}
+
+	return nil
+}
+
+func (c *bfdCollector) collectForSession(re *proto.Sentence, ctx *collectorContext) {
+	remote := re.Map["remote-address"]
+	if remote == "" {
+		remote = re.Map["address"]
+	}
+	// v7 appends the VRF to addresses, e.g. 10.0.0.1%main
+	vrf := ""
+	if i := strings.Index(remote, "%"); i > -1 {
+		remote, vrf = remote[:i], remote[i+1:]
+	}
+	labels := []string{ctx.device.Name, ctx.device.Address, remote, vrf, re.Map["interface"]}
+
+	for _, p := range c.metricProps {
+		value := re.Map[p]
+		if value == "" {
+			continue
+		}
+
+		v, err := c.parseValueForProperty(p, value)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"session":  remote,
+				"property": p,
+				"value":    value,
+				"error":    err,
+			}).Error("error parsing bfd metric value")
+			continue
+		}
+
+		vtype := prometheus.GaugeValue
+		switch p {
+		case "state-changes", "packets-rx", "packets-tx":
+			vtype = prometheus.CounterValue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions[p], vtype, v, labels...)
+	}
+}
+
+func (c *bfdCollector) parseValueForProperty(property, value string) (float64, error) {
+	switch property {
+	case "state":
+		if value == "up" {
+			return 1, nil
+		}
+		return 0, nil
+	case "uptime", "actual-tx-interval", "desired-tx-interval", "required-min-rx", "remote-min-rx":
+		return parseDuration(value)
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
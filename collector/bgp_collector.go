@@ -6,12 +6,15 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
 	"gopkg.in/routeros.v2/proto"
 )
 
 type bgpCollector struct {
 	props        []string
+	propsV7      []string
 	descriptions map[string]*prometheus.Desc
+	infoDesc     *prometheus.Desc
 }
 
 func newBGPCollector() routerOSCollector {
@@ -21,29 +24,51 @@ func newBGPCollector() routerOSCollector {
 }
 
 func (c *bgpCollector) init() {
-	c.props = []string{"name", "remote-as", "state", "prefix-count", "updates-sent", "updates-received", "withdrawn-sent", "withdrawn-received"}
+	c.props = []string{"name", "remote-as", "remote-address", "state", "prefix-count", "updates-sent", "updates-received", "withdrawn-sent", "withdrawn-received"}
+	c.propsV7 = []string{"name", "remote.as", "remote.address", "local.role", "established", "uptime", "prefix-count", "local.messages", "remote.messages", "local.bytes", "remote.bytes"}
 
 	const prefix = "bgp"
 	labelNames := []string{"name", "address", "session", "asn"}
 
 	c.descriptions = make(map[string]*prometheus.Desc)
 	c.descriptions["state"] = description(prefix, "up", "BGP session is established (up = 1)", labelNames)
+	c.descriptions["uptime"] = description(prefix, "uptime_seconds", "time since the BGP session was established", labelNames)
+	c.descriptions["local.messages"] = description(prefix, "messages_sent", "number of BGP messages sent", labelNames)
+	c.descriptions["remote.messages"] = description(prefix, "messages_received", "number of BGP messages received", labelNames)
+	c.descriptions["local.bytes"] = description(prefix, "bytes_sent", "number of BGP bytes sent", labelNames)
+	c.descriptions["remote.bytes"] = description(prefix, "bytes_received", "number of BGP bytes received", labelNames)
 
-	for _, p := range c.props[3:] {
+	for _, p := range c.props[4:] {
 		c.descriptions[p] = descriptionForPropertyName(prefix, p, labelNames)
 	}
+
+	c.infoDesc = description(prefix, "session_info", "BGP session details", append(labelNames, "remote_address", "local_role"))
 }
 
 func (c *bgpCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
 	}
+	ch <- c.infoDesc
 }
 
 func (c *bgpCollector) collect(ctx *collectorContext) error {
-	stats, err := c.fetch(ctx)
+	if ctx.caps.v7() {
+		stats, err := c.fetch(ctx, "/routing/bgp/session/print", c.propsV7)
+		if err != nil {
+			return c.handleError(err)
+		}
+
+		for _, re := range stats {
+			c.collectForSession(re, ctx)
+		}
+
+		return nil
+	}
+
+	stats, err := c.fetch(ctx, "/routing/bgp/peer/print", c.props)
 	if err != nil {
-		return err
+		return c.handleError(err)
 	}
 
 	for _, re := range stats {
@@ -53,8 +78,18 @@ func (c *bgpCollector) collect(ctx *collectorContext) error {
 	return nil
 }
 
-func (c *bgpCollector) fetch(ctx *collectorContext) ([]*proto.Sentence, error) {
-	reply, err := ctx.client.Run("/routing/bgp/peer/print", "=.proplist="+strings.Join(c.props, ","))
+// handleError only passes on connection errors, an error returned by the
+// router (e.g. missing routing package) must not abort the other collectors
+func (c *bgpCollector) handleError(err error) error {
+	if _, ok := err.(*routeros.DeviceError); ok {
+		return nil
+	}
+
+	return err
+}
+
+func (c *bgpCollector) fetch(ctx *collectorContext, path string, props []string) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run(path, "=.proplist="+strings.Join(props, ","))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"device": ctx.device.Name,
@@ -70,7 +105,40 @@ func (c *bgpCollector) collectForStat(re *proto.Sentence, ctx *collectorContext)
 	asn := re.Map["remote-as"]
 	session := re.Map["name"]
 
-	for _, p := range c.props[2:] {
+	ctx.ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1, ctx.device.Name, ctx.device.Address, session, asn, re.Map["remote-address"], "")
+
+	for _, p := range c.props[3:] {
+		c.collectMetricForProperty(p, session, asn, re, ctx)
+	}
+}
+
+func (c *bgpCollector) collectForSession(re *proto.Sentence, ctx *collectorContext) {
+	asn := re.Map["remote.as"]
+	session := re.Map["name"]
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1, ctx.device.Name, ctx.device.Address, session, asn, re.Map["remote.address"], re.Map["local.role"])
+
+	up := 0.0
+	if re.Map["established"] == "true" {
+		up = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["state"], prometheus.GaugeValue, up, ctx.device.Name, ctx.device.Address, session, asn)
+
+	if v := re.Map["uptime"]; v != "" {
+		uptime, err := parseDuration(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":  ctx.device.Name,
+				"session": session,
+				"value":   v,
+				"error":   err,
+			}).Error("error parsing bgp session uptime")
+		} else {
+			ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["uptime"], prometheus.GaugeValue, uptime, ctx.device.Name, ctx.device.Address, session, asn)
+		}
+	}
+
+	for _, p := range c.propsV7[6:] {
 		c.collectMetricForProperty(p, session, asn, re, ctx)
 	}
 }
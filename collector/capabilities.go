@@ -0,0 +1,193 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var capabilitiesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "system", "capabilities_info"),
+	"mikrotik_exporter: RouterOS version, packages and interface types detected on the device",
+	[]string{"name", "address", "version", "major_version", "packages", "interface_types", "poe"},
+	nil,
+)
+
+// capabilities describes what a router supports. A nil value means the
+// capabilities could not be detected, in which case every collector runs.
+type capabilities struct {
+	version        string
+	majorVersion   int
+	packages       map[string]bool
+	interfaceTypes map[string]bool
+	poe            bool
+}
+
+// requirements declares what a collector needs on the router
+type requirements struct {
+	// minVersion is the major RouterOS version, devices without a version
+	// such as SwOS have 0
+	minVersion int
+	packages   []string
+	// v6Packages are only separate packages on RouterOS 6, from 7 on they
+	// are part of the routeros package
+	v6Packages     []string
+	interfaceTypes []string
+	poe            bool
+}
+
+// requirer is implemented by collectors which only apply to some routers
+type requirer interface {
+	requires() requirements
+}
+
+func detectCapabilities(client transport) (*capabilities, error) {
+	caps := &capabilities{
+		packages:       make(map[string]bool),
+		interfaceTypes: make(map[string]bool),
+	}
+
+	reply, err := client.Run("/system/resource/print", "=.proplist=version")
+	if err != nil {
+		return nil, err
+	}
+	for _, re := range reply.Re {
+		caps.version = re.Map["version"]
+	}
+	// the version is unknown e.g. for SwOS devices queried via SNMP
+	if caps.version != "" {
+		caps.majorVersion, err = parseMajorVersion(caps.version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err = client.Run("/system/package/print", "=.proplist=name,disabled")
+	if err != nil {
+		return nil, err
+	}
+	for _, re := range reply.Re {
+		if re.Map["disabled"] != "true" {
+			caps.packages[re.Map["name"]] = true
+		}
+	}
+
+	reply, err = client.Run("/interface/print", "=.proplist=type")
+	if err != nil {
+		return nil, err
+	}
+	for _, re := range reply.Re {
+		caps.interfaceTypes[re.Map["type"]] = true
+	}
+
+	reply, err = client.Run("/interface/ethernet/poe/print", "=count-only=")
+	if err == nil {
+		n, _ := strconv.Atoi(reply.Done.Map["ret"])
+		caps.poe = n > 0
+	}
+
+	return caps, nil
+}
+
+// v7 reports whether the router runs RouterOS 7 or later
+func (caps *capabilities) v7() bool {
+	return caps != nil && caps.majorVersion >= 7
+}
+
+// supports reports whether the router fulfills the requirements of co
+func (caps *capabilities) supports(co routerOSCollector) bool {
+	r, ok := co.(requirer)
+	if !ok || caps == nil {
+		return true
+	}
+
+	req := r.requires()
+	if caps.majorVersion < req.minVersion {
+		return false
+	}
+	if req.poe && !caps.poe {
+		return false
+	}
+	for _, p := range req.packages {
+		if !caps.packages[p] {
+			return false
+		}
+	}
+	for _, p := range req.v6Packages {
+		if !caps.v7() && !caps.packages[p] {
+			return false
+		}
+	}
+	if len(req.interfaceTypes) == 0 {
+		return true
+	}
+	for _, t := range req.interfaceTypes {
+		if caps.interfaceTypes[t] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (caps *capabilities) metric(d *config.Device) prometheus.Metric {
+	return prometheus.MustNewConstMetric(capabilitiesDesc, prometheus.GaugeValue, 1,
+		d.Name, d.Address, caps.version, strconv.Itoa(caps.majorVersion),
+		joinKeys(caps.packages), joinKeys(caps.interfaceTypes), strconv.FormatBool(caps.poe))
+}
+
+func joinKeys(m map[string]bool) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+// capabilitiesFor returns the cached capabilities of the device, detecting
+// them on first use
+func (c *collector) capabilitiesFor(d *config.Device, client transport) *capabilities {
+	c.capsMu.Lock()
+	caps, ok := c.caps[d.Name]
+	c.capsMu.Unlock()
+	if ok {
+		return caps
+	}
+
+	caps, err := detectCapabilities(client)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": d.Name,
+			"error":  err,
+		}).Warn("error detecting device capabilities, running all collectors")
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"device":          d.Name,
+		"version":         caps.version,
+		"packages":        joinKeys(caps.packages),
+		"interface_types": joinKeys(caps.interfaceTypes),
+	}).Debug("detected device capabilities")
+
+	c.capsMu.Lock()
+	c.caps[d.Name] = caps
+	c.capsMu.Unlock()
+
+	return caps
+}
+
+// forgetCapabilities drops the cached capabilities, e.g. when the device
+// could not be reached as it might have been upgraded meanwhile
+func (c *collector) forgetCapabilities(d *config.Device) {
+	c.capsMu.Lock()
+	delete(c.caps, d.Name)
+	c.capsMu.Unlock()
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitiesSupports(t *testing.T) {
+	caps := &capabilities{
+		version:        "7.12.1 (stable)",
+		majorVersion:   7,
+		packages:       map[string]bool{"routeros": true, "wireless": true},
+		interfaceTypes: map[string]bool{"ether": true, "wlan": true},
+	}
+
+	assert.True(t, caps.v7())
+	assert.True(t, caps.supports(newInterfaceCollector()))
+	assert.True(t, caps.supports(newWlanSTACollector()))
+	assert.True(t, caps.supports(newCapsmanCollector()))
+	assert.False(t, caps.supports(newLteCollector()))
+	assert.False(t, caps.supports(newPOECollector()))
+	assert.True(t, caps.supports(newOSPFCollector()))
+	assert.True(t, caps.supports(newMPLSCollector()))
+
+	// routing and mpls are separate packages on v6
+	v6 := &capabilities{
+		version:      "6.49.10 (long-term)",
+		majorVersion: 6,
+		packages:     map[string]bool{"system": true, "routing": true},
+	}
+	assert.True(t, v6.supports(newOSPFCollector()))
+	assert.True(t, v6.supports(newBFDCollector()))
+	assert.False(t, v6.supports(newMPLSCollector()))
+	assert.True(t, v6.supports(newQueueCollector()))
+
+	swos := &capabilities{packages: map[string]bool{}}
+	assert.False(t, swos.supports(newVRRPCollector()))
+
+	// unknown capabilities must not skip anything
+	var unknown *capabilities
+	assert.False(t, unknown.v7())
+	assert.True(t, unknown.supports(newLteCollector()))
+}
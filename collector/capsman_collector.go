@@ -34,6 +34,10 @@ func (c *capsmanCollector) init() {
 	}
 }
 
+func (c *capsmanCollector) requires() requirements {
+	return requirements{packages: []string{"wireless"}}
+}
+
 func (c *capsmanCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+
+	"mikrotik-exporter/config"
+)
+
+// ConnectionCheck is the result of testing the connection to a device
+type ConnectionCheck struct {
+	Device     string
+	Address    string
+	Err        error
+	Collectors []CollectorCheck
+}
+
+// CollectorCheck reports whether the API path read by a collector is
+// reachable with the configured user
+type CollectorCheck struct {
+	Name       string
+	Path       string
+	Err        error
+	SkipReason string
+}
+
+// OK reports whether login and all collector checks succeeded
+func (c ConnectionCheck) OK() bool {
+	if c.Err != nil {
+		return false
+	}
+
+	for _, co := range c.Collectors {
+		if co.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckConnections dials and logs in to every configured device and probes
+// the API path of every enabled collector
+func CheckConnections(cfg *config.Config, opts ...Option) []ConnectionCheck {
+	c := newCollector(cfg, opts...)
+	devices := c.resolveDevices()
+
+	res := make([]ConnectionCheck, len(devices))
+	wg := sync.WaitGroup{}
+	wg.Add(len(devices))
+
+	for i, dev := range devices {
+		go func(i int, d config.Device) {
+			res[i] = c.checkDevice(&d)
+			wg.Done()
+		}(i, dev)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+func (c *collector) checkDevice(d *config.Device) ConnectionCheck {
+	res := ConnectionCheck{Device: d.Name, Address: d.Address}
+
+	cl, err := c.connect(d)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer cl.Close()
+
+	caps := c.capabilitiesFor(d, cl)
+	for _, co := range c.collectors {
+		name, sentence := probeFor(co, caps)
+		check := CollectorCheck{
+			Name: name,
+			Path: strings.TrimSuffix(sentence[0], "/print"),
+		}
+
+		check.SkipReason = skipReason(cl, caps, co)
+		if check.SkipReason == "" {
+			_, check.Err = cl.Run(sentence...)
+		}
+
+		res.Collectors = append(res.Collectors, check)
+	}
+
+	return res
+}
+
+// probeFor returns the name of the collector and a cheap API sentence reading
+// the menu the collector depends on
+func probeFor(co routerOSCollector, caps *capabilities) (string, []string) {
+	switch co.(type) {
+	case *interfaceCollector:
+		return "interface", []string{"/interface/print", "=count-only="}
+	case *resourceCollector:
+		return "resource", []string{"/system/resource/print"}
+	case *bgpCollector:
+		if caps.v7() {
+			return "bgp", []string{"/routing/bgp/session/print", "=count-only="}
+		}
+		return "bgp", []string{"/routing/bgp/peer/print", "=count-only="}
+	case *routesCollector:
+		return "routes", []string{"/ip/route/print", "=count-only="}
+	case *dhcpCollector:
+		return "dhcp", []string{"/ip/dhcp-server/print", "=count-only="}
+	case *dhcpLeaseCollector:
+		return "dhcpl", []string{"/ip/dhcp-server/lease/print", "=count-only="}
+	case *dhcpv6Collector:
+		return "dhcpv6", []string{"/ipv6/dhcp-server/print", "=count-only="}
+	case *firmwareCollector:
+		return "firmware", []string{"/system/package/print", "=count-only="}
+	case *healthCollector:
+		return "health", []string{"/system/health/print"}
+	case *poeCollector:
+		return "poe", []string{"/interface/ethernet/poe/print", "=count-only="}
+	case *poolCollector:
+		return "pools", []string{"/ip/pool/print", "=count-only="}
+	case *opticsCollector:
+		return "optics", []string{"/interface/ethernet/print", "=count-only="}
+	case *w60gInterfaceCollector:
+		return "w60g", []string{"/interface/w60g/print", "=count-only="}
+	case *wlanSTACollector:
+		return "wlansta", []string{"/interface/wireless/registration-table/print", "=count-only="}
+	case *capsmanCollector:
+		return "capsman", []string{"/caps-man/registration-table/print", "=count-only="}
+	case *wlanIFCollector:
+		return "wlanif", []string{"/interface/wireless/print", "=count-only="}
+	case *monitorCollector:
+		return "monitor", []string{"/interface/ethernet/print", "=count-only="}
+	case *ipsecCollector:
+		return "ipsec", []string{"/ip/ipsec/policy/print", "=count-only="}
+	case *conntrackCollector:
+		return "conntrack", []string{"/ip/firewall/connection/tracking/print"}
+	case *lteCollector:
+		return "lte", []string{"/interface/lte/print", "=count-only="}
+	case *netwatchCollector:
+		return "netwatch", []string{"/tool/netwatch/print", "=count-only="}
+	case *neighborCollector:
+		return "neighbor", []string{"/ip/neighbor/print", "=count-only="}
+	case *logCollector:
+		return "log", []string{"/log/print", "=count-only="}
+	case *trafficCollector:
+		return "traffic", []string{"/interface/print", "=count-only="}
+	case *firewallCollector:
+		return "firewall", []string{"/ip/firewall/filter/print", "=count-only="}
+	case *addressListCollector:
+		return "address_list", []string{"/ip/firewall/address-list/print", "=count-only="}
+	case *queueCollector:
+		return "queue", []string{"/queue/simple/print", "=count-only="}
+	case *ospfCollector:
+		return "ospf", []string{"/routing/ospf/neighbor/print", "=count-only="}
+	case *bfdCollector:
+		if caps.v7() {
+			return "bfd", []string{"/routing/bfd/session/print", "=count-only="}
+		}
+		return "bfd", []string{"/routing/bfd/neighbor/print", "=count-only="}
+	case *mplsCollector:
+		return "mpls", []string{"/mpls/ldp/neighbor/print", "=count-only="}
+	case *vrrpCollector:
+		return "vrrp", []string{"/interface/vrrp/print", "=count-only="}
+	}
+
+	return "unknown", []string{"/system/identity/print"}
+}
@@ -47,12 +47,27 @@ var (
 	)
 )
 
+// DeviceLabelNames lists the labels identifying the router a metric belongs
+// to, in order of precedence. Most collectors use "name", the scrape metrics use
+// "device" and the firmware collector uses "devicename" as it reports the
+// package name in "name".
+var DeviceLabelNames = []string{"devicename", "device", "name"}
+
 type collector struct {
 	devices     []config.Device
+	sources     []DeviceSource
 	collectors  []routerOSCollector
 	timeout     time.Duration
 	enableTLS   bool
 	insecureTLS bool
+
+	capsMu sync.Mutex
+	caps   map[string]*capabilities
+}
+
+// DeviceSource provides devices discovered at runtime
+type DeviceSource interface {
+	Devices() []config.Device
 }
 
 // WithBGP enables BGP routing metrics
@@ -175,6 +190,14 @@ func WithTLS(insecure bool) Option {
 	}
 }
 
+// WithInsecureTLS skips the certificate verification of devices connected
+// via TLS or HTTPS, without enabling TLS for all devices
+func WithInsecureTLS() Option {
+	return func(c *collector) {
+		c.insecureTLS = true
+	}
+}
+
 // WithIpsec enables ipsec metrics
 func WithIpsec() Option {
 	return func(c *collector) {
@@ -203,6 +226,83 @@ func WithNetwatch() Option {
 	}
 }
 
+// WithNeighbor enables IP neighbor metrics
+func WithNeighbor(cfg config.Neighbor) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newNeighborCollector(cfg))
+	}
+}
+
+// WithLog enables log entry counters
+func WithLog(cfg config.Log) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newLogCollector(cfg))
+	}
+}
+
+// WithTraffic enables real-time interface traffic metrics
+func WithTraffic(cfg config.Traffic) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newTrafficCollector(cfg))
+	}
+}
+
+// WithFirewall enables firewall rule metrics
+func WithFirewall(cfg config.Firewall) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newFirewallCollector(cfg))
+	}
+}
+
+// WithAddressList enables firewall address list metrics
+func WithAddressList(cfg config.AddressList) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newAddressListCollector(cfg))
+	}
+}
+
+// WithQueue enables simple queue and queue tree metrics
+func WithQueue() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newQueueCollector())
+	}
+}
+
+// WithOSPF enables OSPF metrics
+func WithOSPF() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newOSPFCollector())
+	}
+}
+
+// WithBFD enables BFD session metrics
+func WithBFD() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newBFDCollector())
+	}
+}
+
+// WithMPLS enables MPLS LDP and VPLS metrics
+func WithMPLS() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newMPLSCollector())
+	}
+}
+
+// WithVRRP enables VRRP interface metrics
+func WithVRRP() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newVRRPCollector())
+	}
+}
+
+// WithDeviceSource adds devices discovered at runtime to the configured ones
+func WithDeviceSource(src DeviceSource) Option {
+	return func(c *collector) {
+		c.sources = append(c.sources, src)
+	}
+}
+
 // Option applies options to collector
 type Option func(*collector)
 
@@ -212,9 +312,14 @@ func NewCollector(cfg *config.Config, opts ...Option) (prometheus.Collector, err
 		"numDevices": len(cfg.Devices),
 	}).Info("setting up collector for devices")
 
+	return newCollector(cfg, opts...), nil
+}
+
+func newCollector(cfg *config.Config, opts ...Option) *collector {
 	c := &collector{
 		devices: cfg.Devices,
 		timeout: DefaultTimeout,
+		caps:    make(map[string]*capabilities),
 		collectors: []routerOSCollector{
 			newInterfaceCollector(),
 			newResourceCollector(),
@@ -225,13 +330,14 @@ func NewCollector(cfg *config.Config, opts ...Option) (prometheus.Collector, err
 		o(c)
 	}
 
-	return c, nil
+	return c
 }
 
 // Describe implements the prometheus.Collector interface.
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- capabilitiesDesc
 
 	for _, co := range c.collectors {
 		co.describe(ch)
@@ -242,6 +348,23 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
 
+	realDevices := c.resolveDevices()
+
+	wg.Add(len(realDevices))
+
+	for _, dev := range realDevices {
+		go func(d config.Device) {
+			c.collectForDevice(d, ch)
+			wg.Done()
+		}(dev)
+	}
+
+	wg.Wait()
+}
+
+// resolveDevices returns the configured devices, expanding SRV records into
+// the devices they point to
+func (c *collector) resolveDevices() []config.Device {
 	var realDevices []config.Device
 
 	for _, dev := range c.devices {
@@ -275,6 +398,10 @@ func (c *collector) Collect(ch chan<- prometheus.Metric) {
 					d.Address = strings.TrimRight(s.Target, ".")
 					d.User = dev.User
 					d.Password = dev.Password
+					d.TLS = dev.TLS
+					d.Transport = dev.Transport
+					d.SSH = dev.SSH
+					d.SNMP = dev.SNMP
 					_ = c.getIdentity(&d)
 					realDevices = append(realDevices, d)
 				}
@@ -284,16 +411,31 @@ func (c *collector) Collect(ch chan<- prometheus.Metric) {
 		}
 	}
 
-	wg.Add(len(realDevices))
+	return append(realDevices, c.discoveredDevices(realDevices)...)
+}
 
-	for _, dev := range realDevices {
-		go func(d config.Device) {
-			c.collectForDevice(d, ch)
-			wg.Done()
-		}(dev)
+// discoveredDevices returns the devices of all sources which are not
+// configured already
+func (c *collector) discoveredDevices(known []config.Device) []config.Device {
+	seen := make(map[string]bool)
+	for _, d := range known {
+		seen[d.Name] = true
+		seen[d.Address] = true
 	}
 
-	wg.Wait()
+	var res []config.Device
+	for _, src := range c.sources {
+		for _, d := range src.Devices() {
+			if seen[d.Name] || seen[d.Address] {
+				continue
+			}
+			seen[d.Name] = true
+			seen[d.Address] = true
+			res = append(res, d)
+		}
+	}
+
+	return res
 }
 
 func (c *collector) getIdentity(d *config.Device) error {
@@ -346,12 +488,28 @@ func (c *collector) connectAndCollect(d *config.Device, ch chan<- prometheus.Met
 			"device": d.Name,
 			"error":  err,
 		}).Error("error dialing device")
+		c.forgetCapabilities(d)
 		return err
 	}
 	defer cl.Close()
 
+	caps := c.capabilitiesFor(d, cl)
+	if caps != nil {
+		ch <- caps.metric(d)
+	}
+
 	for _, co := range c.collectors {
-		ctx := &collectorContext{ch, d, cl}
+		if reason := skipReason(cl, caps, co); reason != "" {
+			name, _ := probeFor(co, caps)
+			log.WithFields(log.Fields{
+				"device":    d.Name,
+				"collector": name,
+				"reason":    reason,
+			}).Debug("skipping collector")
+			continue
+		}
+
+		ctx := &collectorContext{ch, d, cl, caps}
 		err = co.collect(ctx)
 		if err != nil {
 			return err
@@ -361,12 +519,12 @@ func (c *collector) connectAndCollect(d *config.Device, ch chan<- prometheus.Met
 	return nil
 }
 
-func (c *collector) connect(d *config.Device) (*routeros.Client, error) {
+func (c *collector) connectAPI(d *config.Device) (*routeros.Client, error) {
 	var conn net.Conn
 	var err error
 
 	log.WithField("device", d.Name).Debug("trying to Dial")
-	if !c.enableTLS {
+	if !c.enableTLS && !d.TLS {
 		if (d.Port) == "" {
 			d.Port = apiPort
 		}
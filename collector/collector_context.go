@@ -4,11 +4,11 @@ import (
 	"mikrotik-exporter/config"
 
 	"github.com/prometheus/client_golang/prometheus"
-	routeros "gopkg.in/routeros.v2"
 )
 
 type collectorContext struct {
 	ch     chan<- prometheus.Metric
 	device *config.Device
-	client *routeros.Client
+	client transport
+	caps   *capabilities
 }
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+var firewallTables = []string{"filter", "nat", "mangle", "raw"}
+
+type firewallCollector struct {
+	props         []string
+	tables        []string
+	commentedOnly bool
+	comments      *regexp.Regexp
+	bytesDesc     *prometheus.Desc
+	packetsDesc   *prometheus.Desc
+}
+
+func newFirewallCollector(cfg config.Firewall) routerOSCollector {
+	c := &firewallCollector{
+		tables:        cfg.Tables,
+		commentedOnly: cfg.CommentedOnly,
+	}
+
+	if len(c.tables) == 0 {
+		c.tables = firewallTables
+	}
+
+	if cfg.Comments != "" {
+		re, err := regexp.Compile(cfg.Comments)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"comments": cfg.Comments,
+				"error":    err,
+			}).Fatal("invalid firewall comment filter")
+		}
+		c.comments = re
+	}
+
+	c.init()
+	return c
+}
+
+func (c *firewallCollector) init() {
+	c.props = []string{".id", "chain", "action", "comment", "bytes", "packets"}
+
+	const prefix = "firewall_rule"
+	labelNames := []string{"name", "address", "ip_version", "table", "chain", "action", "comment", "rule_id"}
+	c.bytesDesc = description(prefix, "bytes_total", "number of bytes matched by the firewall rule", labelNames)
+	c.packetsDesc = description(prefix, "packets_total", "number of packets matched by the firewall rule", labelNames)
+}
+
+func (c *firewallCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytesDesc
+	ch <- c.packetsDesc
+}
+
+func (c *firewallCollector) collect(ctx *collectorContext) error {
+	for _, table := range c.tables {
+		err := c.collectForTable("4", "ip", table, ctx)
+		if err != nil {
+			return err
+		}
+
+		err = c.collectForTable("6", "ipv6", table, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *firewallCollector) collectForTable(ipVersion, topic, table string, ctx *collectorContext) error {
+	reply, err := ctx.client.Run(fmt.Sprintf("/%s/firewall/%s/print", topic, table), "?disabled=false", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		// e.g. IPv6 NAT before RouterOS 7 or a disabled ipv6 package
+		if _, ok := err.(*routeros.DeviceError); ok {
+			log.WithFields(log.Fields{
+				"device":     ctx.device.Name,
+				"ip_version": ipVersion,
+				"table":      table,
+				"error":      err,
+			}).Debug("firewall table not available")
+			return nil
+		}
+
+		log.WithFields(log.Fields{
+			"device":     ctx.device.Name,
+			"ip_version": ipVersion,
+			"table":      table,
+			"error":      err,
+		}).Error("error fetching firewall rule metrics")
+		return err
+	}
+
+	for _, re := range reply.Re {
+		if !c.includes(re.Map["comment"]) {
+			continue
+		}
+
+		c.collectForRule(ipVersion, table, re, ctx)
+	}
+
+	return nil
+}
+
+func (c *firewallCollector) includes(comment string) bool {
+	if c.commentedOnly && comment == "" {
+		return false
+	}
+
+	return c.comments == nil || c.comments.MatchString(comment)
+}
+
+func (c *firewallCollector) collectForRule(ipVersion, table string, re *proto.Sentence, ctx *collectorContext) {
+	labels := []string{ctx.device.Name, ctx.device.Address, ipVersion, table, re.Map["chain"], re.Map["action"], re.Map["comment"], re.Map[".id"]}
+
+	c.collectMetric(c.bytesDesc, "bytes", re, labels, ctx)
+	c.collectMetric(c.packetsDesc, "packets", re, labels, ctx)
+}
+
+func (c *firewallCollector) collectMetric(desc *prometheus.Desc, property string, re *proto.Sentence, labels []string, ctx *collectorContext) {
+	value := re.Map[property]
+	if value == "" {
+		return
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":   ctx.device.Name,
+			"rule":     re.Map[".id"],
+			"property": property,
+			"value":    value,
+			"error":    err,
+		}).Error("error parsing firewall rule metric value")
+		return
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, labels...)
+}
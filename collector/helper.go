@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,8 +15,14 @@ import (
 )
 
 var durationRegex *regexp.Regexp
+var millisecondsRegex = regexp.MustCompile(`(\d+)ms$`)
 var durationParts [6]time.Duration
 
+var (
+	metricPrefixesMu sync.Mutex
+	metricPrefixes   = map[string]bool{"scrape": true}
+)
+
 func init() {
 	durationRegex = regexp.MustCompile(`(?:(\d*)w)?(?:(\d*)d)?(?:(\d*)h)?(?:(\d*)m)?(?:(\d*)s)?(?:(\d*)ms)?`)
 	durationParts = [6]time.Duration{time.Hour * 168, time.Hour * 24, time.Hour, time.Minute, time.Second, time.Millisecond}
@@ -29,6 +37,7 @@ func descriptionForPropertyName(prefix, property string, labelNames []string) *p
 }
 
 func descriptionForPropertyNameHelpText(prefix, property string, labelNames []string, helpText string) *prometheus.Desc {
+	registerMetricPrefix(prefix)
 	return prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, prefix, metricStringCleanup(property)),
 		helpText,
@@ -38,6 +47,7 @@ func descriptionForPropertyNameHelpText(prefix, property string, labelNames []st
 }
 
 func description(prefix, name, helpText string, labelNames []string) *prometheus.Desc {
+	registerMetricPrefix(prefix)
 	return prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, prefix, metricStringCleanup(name)),
 		helpText,
@@ -46,10 +56,42 @@ func description(prefix, name, helpText string, labelNames []string) *prometheus
 	)
 }
 
+func registerMetricPrefix(prefix string) {
+	metricPrefixesMu.Lock()
+	metricPrefixes[prefix] = true
+	metricPrefixesMu.Unlock()
+}
+
+// MetricPrefixes returns the metric name prefixes (e.g. "interface" or
+// "wlan_station") used by the collectors created so far, longest first
+func MetricPrefixes() []string {
+	metricPrefixesMu.Lock()
+	defer metricPrefixesMu.Unlock()
+
+	prefixes := make([]string, 0, len(metricPrefixes))
+	for p := range metricPrefixes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+
+	return prefixes
+}
+
 func splitStringToFloats(metric string) (float64, float64, error) {
-	strs := strings.Split(metric, ",")
-	if len(strs) == 0 {
-		return 0, 0, nil
+	return splitStringToFloatsBySeparator(metric, ",")
+}
+
+// splitStringToFloatsBySeparator parses pairs like the "upload/download"
+// values of simple queues
+func splitStringToFloatsBySeparator(metric, sep string) (float64, float64, error) {
+	strs := strings.Split(metric, sep)
+	if len(strs) < 2 {
+		return math.NaN(), math.NaN(), fmt.Errorf("expected two values separated by %q", sep)
 	}
 	m1, err := strconv.ParseFloat(strs[0], 64)
 	if err != nil {
@@ -65,6 +107,17 @@ func splitStringToFloats(metric string) (float64, float64, error) {
 func parseDuration(duration string) (float64, error) {
 	var u time.Duration
 
+	// milliseconds are split off first, the regex would take "200ms" for
+	// 200 minutes
+	if m := millisecondsRegex.FindStringSubmatch(duration); m != nil {
+		v, err := strconv.Atoi(m[1])
+		if err != nil {
+			return float64(0), err
+		}
+		u = time.Duration(v) * time.Millisecond
+		duration = duration[:len(duration)-len(m[0])]
+	}
+
 	reMatch := durationRegex.FindAllStringSubmatch(duration, -1)
 
 	// should get one and only one match back on the regex
@@ -88,3 +141,19 @@ func parseDuration(duration string) (float64, error) {
 	}
 	return u.Seconds(), nil
 }
+
+// parseMajorVersion returns the major version of a RouterOS version string
+// like "7.12.1 (stable)"
+func parseMajorVersion(version string) (int, error) {
+	v := strings.TrimSpace(version)
+	if i := strings.IndexAny(v, ". "); i >= 0 {
+		v = v[:i]
+	}
+
+	major, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid routeros version %q", version)
+	}
+
+	return major, nil
+}
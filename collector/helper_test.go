@@ -79,6 +79,18 @@ func TestSplitStringToFloats(t *testing.T) {
 	}
 }
 
+func TestSplitStringToFloatsBySeparator(t *testing.T) {
+	up, down, err := splitStringToFloatsBySeparator("1024/2048", "/")
+	assert.NoError(t, err)
+	assert.Equal(t, 1024.0, up)
+	assert.Equal(t, 2048.0, down)
+
+	up, down, err = splitStringToFloatsBySeparator("1024", "/")
+	assert.Error(t, err)
+	assert.True(t, math.IsNaN(up))
+	assert.True(t, math.IsNaN(down))
+}
+
 func TestParseDuration(t *testing.T) {
 	var testCases = []struct {
 		input    string
@@ -105,6 +117,16 @@ func TestParseDuration(t *testing.T) {
 			4786440,
 			false,
 		},
+		{
+			"200ms",
+			0.2,
+			false,
+		},
+		{
+			"1m30s250ms",
+			90.25,
+			false,
+		},
 		{
 			"59",
 			0,
@@ -135,3 +157,31 @@ func TestParseDuration(t *testing.T) {
 		assert.Equal(t, testCase.output, f)
 	}
 }
+
+func TestParseMajorVersion(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		output   int
+		hasError bool
+	}{
+		{"6.48.1 (stable)", 6, false},
+		{"7.12.1 (stable)", 7, false},
+		{"7.13beta2", 7, false},
+		{"v7", 0, true},
+		{"7", 7, false},
+		{"", 0, true},
+	}
+
+	for _, testCase := range testCases {
+		v, err := parseMajorVersion(testCase.input)
+
+		switch testCase.hasError {
+		case true:
+			assert.Error(t, err)
+		case false:
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, testCase.output, v)
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"mikrotik-exporter/config"
+)
+
+// DeviceInfo holds the identity and hardware details of a router
+type DeviceInfo struct {
+	Identity     string
+	Model        string
+	SerialNumber string
+	Firmware     string
+}
+
+// Identify logs in to the device and reads its identity and routerboard
+// details
+func Identify(d config.Device, opts ...Option) (DeviceInfo, error) {
+	c := newCollector(&config.Config{}, opts...)
+	info := DeviceInfo{}
+
+	cl, err := c.connect(&d)
+	if err != nil {
+		return info, err
+	}
+	defer cl.Close()
+
+	reply, err := cl.Run("/system/identity/print")
+	if err != nil {
+		return info, err
+	}
+	for _, re := range reply.Re {
+		info.Identity = re.Map["name"]
+	}
+
+	reply, err = cl.Run("/system/routerboard/print", "=.proplist=model,serial-number,current-firmware")
+	if err != nil {
+		return info, err
+	}
+	for _, re := range reply.Re {
+		info.Model = re.Map["model"]
+		info.SerialNumber = re.Map["serial-number"]
+		info.Firmware = re.Map["current-firmware"]
+	}
+
+	return info, nil
+}
@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+var logSeverities = map[string]bool{
+	"debug":    true,
+	"info":     true,
+	"warning":  true,
+	"error":    true,
+	"critical": true,
+}
+
+type logPattern struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+type logEntryKey struct {
+	topics   string
+	severity string
+}
+
+// logState holds the cursor and counters of a device
+type logState struct {
+	cursor   uint64
+	uptime   float64
+	entries  map[logEntryKey]float64
+	patterns map[string]float64
+}
+
+type logCollector struct {
+	props        []string
+	entriesDesc  *prometheus.Desc
+	patternsDesc *prometheus.Desc
+	patterns     []logPattern
+
+	mu     sync.Mutex
+	states map[string]*logState
+}
+
+func newLogCollector(cfg config.Log) routerOSCollector {
+	c := &logCollector{states: make(map[string]*logState)}
+
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"pattern": p.Name,
+				"error":   err,
+			}).Fatal("invalid log pattern")
+		}
+		c.patterns = append(c.patterns, logPattern{name: p.Name, regex: re})
+	}
+
+	c.init()
+	return c
+}
+
+func (c *logCollector) init() {
+	c.props = []string{".id", "topics", "message"}
+
+	c.entriesDesc = description("log", "entries_total", "number of log entries by topics and severity", []string{"name", "address", "topics", "severity"})
+	c.patternsDesc = description("log", "pattern_matches_total", "number of log messages matching the configured pattern", []string{"name", "address", "pattern"})
+}
+
+func (c *logCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entriesDesc
+	ch <- c.patternsDesc
+}
+
+func (c *logCollector) collect(ctx *collectorContext) error {
+	uptime, err := c.fetchUptime(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	cursor := c.cursorFor(ctx.device.Name, uptime)
+	c.mu.Unlock()
+
+	stats, err := c.fetch(ctx, cursor)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.update(ctx.device.Name, uptime, stats)
+
+	for k, v := range state.entries {
+		ctx.ch <- prometheus.MustNewConstMetric(c.entriesDesc, prometheus.CounterValue, v, ctx.device.Name, ctx.device.Address, k.topics, k.severity)
+	}
+	for _, p := range c.patterns {
+		ctx.ch <- prometheus.MustNewConstMetric(c.patternsDesc, prometheus.CounterValue, state.patterns[p.name], ctx.device.Name, ctx.device.Address, p.name)
+	}
+
+	return nil
+}
+
+// fetchUptime returns the uptime of the device, which is used to detect
+// reboots clearing the log
+func (c *logCollector) fetchUptime(ctx *collectorContext) (float64, error) {
+	reply, err := ctx.client.Run("/system/resource/print", "=.proplist=uptime")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching uptime for log entries")
+		return 0, err
+	}
+
+	if len(reply.Re) == 0 {
+		return 0, nil
+	}
+
+	uptime, err := parseDuration(reply.Re[0].Map["uptime"])
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"value":  reply.Re[0].Map["uptime"],
+			"error":  err,
+		}).Error("error parsing uptime for log entries")
+		return 0, err
+	}
+
+	return uptime, nil
+}
+
+// cursorFor returns the id after which entries have to be fetched, 0 if all
+// entries are needed (unknown device or reboot)
+func (c *logCollector) cursorFor(device string, uptime float64) uint64 {
+	state, ok := c.states[device]
+	if !ok || uptime < state.uptime {
+		return 0
+	}
+
+	return state.cursor
+}
+
+// fetch returns the entries after the cursor, the filtering is done by the
+// router so only new entries are transferred
+func (c *logCollector) fetch(ctx *collectorContext, cursor uint64) ([]*proto.Sentence, error) {
+	sentence := []string{"/log/print", "=.proplist=" + strings.Join(c.props, ",")}
+	if cursor > 0 {
+		sentence = append(sentence, "?>.id=*"+strings.ToUpper(strconv.FormatUint(cursor, 16)))
+	}
+
+	reply, err := ctx.client.Run(sentence...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching log entries")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+// update counts the entries after the cursor of the device. Entries present
+// when a device is seen the first time are skipped, if the uptime went back
+// (i.e. the device rebooted and the log restarted) all entries are counted.
+func (c *logCollector) update(device string, uptime float64, stats []*proto.Sentence) *logState {
+	var maxID uint64
+	for _, re := range stats {
+		if id, ok := parseLogID(re.Map[".id"]); ok && id > maxID {
+			maxID = id
+		}
+	}
+
+	state, ok := c.states[device]
+	if !ok {
+		state = &logState{
+			cursor:   maxID,
+			uptime:   uptime,
+			entries:  make(map[logEntryKey]float64),
+			patterns: make(map[string]float64),
+		}
+		c.states[device] = state
+		return state
+	}
+
+	cursor := state.cursor
+	if uptime < state.uptime {
+		cursor = 0
+	}
+
+	for _, re := range stats {
+		id, ok := parseLogID(re.Map[".id"])
+		if !ok || id <= cursor {
+			continue
+		}
+
+		topics, severity := splitLogTopics(re.Map["topics"])
+		state.entries[logEntryKey{topics, severity}]++
+
+		for _, p := range c.patterns {
+			if p.regex.MatchString(re.Map["message"]) {
+				state.patterns[p.name]++
+			}
+		}
+	}
+
+	// the router only returns entries after the cursor, without new entries
+	// the cursor stays
+	if maxID > cursor {
+		cursor = maxID
+	}
+	state.cursor = cursor
+	state.uptime = uptime
+	return state
+}
+
+// parseLogID parses ids like *1A2B
+func parseLogID(id string) (uint64, bool) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(id, "*"), 16, 64)
+	return v, err == nil
+}
+
+// splitLogTopics separates the severity from the topics of a log entry
+func splitLogTopics(topics string) (string, string) {
+	severity := ""
+	rest := []string{}
+	for _, t := range strings.Split(topics, ",") {
+		if logSeverities[t] && severity == "" {
+			severity = t
+			continue
+		}
+		rest = append(rest, t)
+	}
+	sort.Strings(rest)
+
+	return strings.Join(rest, ","), severity
+}
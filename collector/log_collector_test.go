@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/routeros.v2/proto"
+)
+
+func logEntry(id, topics, message string) *proto.Sentence {
+	return &proto.Sentence{Map: map[string]string{".id": id, "topics": topics, "message": message}}
+}
+
+func TestLogCollectorUpdate(t *testing.T) {
+	c := newLogCollector(config.Log{Patterns: []config.LogPattern{
+		{Name: "login_failure", Regex: "login failure for user"},
+	}}).(*logCollector)
+
+	entries := []*proto.Sentence{
+		logEntry("*1", "system,info", "router rebooted"),
+		logEntry("*2", "system,error,critical", "login failure for user admin from 10.0.0.1 via ssh"),
+	}
+
+	// existing entries are skipped on first sight
+	state := c.update("router1", 100, entries)
+	assert.Empty(t, state.entries)
+	assert.Equal(t, uint64(2), state.cursor)
+
+	entries = append(entries,
+		logEntry("*3", "system,error,critical", "login failure for user admin from 10.0.0.1 via ssh"),
+		logEntry("*A", "interface,info", "ether1 link down"),
+	)
+	state = c.update("router1", 160, entries)
+	assert.Equal(t, map[logEntryKey]float64{
+		{"critical,system", "error"}: 1,
+		{"interface", "info"}:        1,
+	}, state.entries)
+	assert.Equal(t, 1.0, state.patterns["login_failure"])
+	assert.Equal(t, uint64(10), state.cursor)
+
+	// the same entries must not be counted twice
+	state = c.update("router1", 220, entries)
+	assert.Equal(t, 1.0, state.patterns["login_failure"])
+
+	// no new entries returned by the router keep the cursor
+	state = c.update("router1", 280, nil)
+	assert.Equal(t, uint64(10), state.cursor)
+	assert.Equal(t, uint64(10), c.cursorFor("router1", 300))
+
+	// the log restarted after a reboot, even with more entries than before
+	assert.Equal(t, uint64(0), c.cursorFor("router1", 30))
+	rebooted := []*proto.Sentence{
+		logEntry("*1", "system,error,critical", "login failure for user admin from 10.0.0.1 via ssh"),
+	}
+	for i := 2; i <= 11; i++ {
+		rebooted = append(rebooted, logEntry(fmt.Sprintf("*%X", i), "system,info", "router rebooted"))
+	}
+	state = c.update("router1", 30, rebooted)
+	assert.Equal(t, 2.0, state.patterns["login_failure"])
+	assert.Equal(t, 10.0, state.entries[logEntryKey{"system", "info"}])
+	assert.Equal(t, uint64(11), state.cursor)
+}
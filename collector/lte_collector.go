@@ -30,6 +30,10 @@ func (c *lteCollector) init() {
 	}
 }
 
+func (c *lteCollector) requires() requirements {
+	return requirements{interfaceTypes: []string{"lte"}}
+}
+
 func (c *lteCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -0,0 +1,221 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type mplsCollector struct {
+	ldpProps        []string
+	vplsProps       []string
+	ldpUpDesc       *prometheus.Desc
+	ldpBindingsDesc *prometheus.Desc
+	forwardingDesc  *prometheus.Desc
+	vplsRunningDesc *prometheus.Desc
+	vplsInfoDesc    *prometheus.Desc
+}
+
+func newMPLSCollector() routerOSCollector {
+	c := &mplsCollector{}
+	c.init()
+	return c
+}
+
+func (c *mplsCollector) init() {
+	c.ldpProps = []string{"peer", "transport", "operational", "disabled"}
+	// v6 names the remote PE "remote-peer", v7 "peer"
+	c.vplsProps = []string{"name", "remote-peer", "peer", "vpls-id", "running", "disabled"}
+
+	const prefix = "mpls"
+	ldpLabels := []string{"name", "address", "peer", "transport_address"}
+	c.ldpUpDesc = description(prefix, "ldp_neighbor_up", "LDP neighbor is operational (up = 1)", ldpLabels)
+	c.ldpBindingsDesc = description(prefix, "ldp_neighbor_bindings", "number of label bindings received from the LDP neighbor", ldpLabels)
+	c.forwardingDesc = description(prefix, "forwarding_table_entries", "number of entries in the MPLS forwarding table", []string{"name", "address"})
+	vplsLabels := []string{"name", "address", "interface", "remote_peer", "vpls_id"}
+	c.vplsRunningDesc = description(prefix, "vpls_running", "VPLS tunnel is running (running = 1)", vplsLabels)
+	c.vplsInfoDesc = description(prefix, "vpls_info", "VPLS tunnel details", append(vplsLabels, "local_label", "remote_label"))
+}
+
+func (c *mplsCollector) requires() requirements {
+	return requirements{minVersion: 6, v6Packages: []string{"mpls"}}
+}
+
+func (c *mplsCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ldpUpDesc
+	ch <- c.ldpBindingsDesc
+	ch <- c.forwardingDesc
+	ch <- c.vplsRunningDesc
+	ch <- c.vplsInfoDesc
+}
+
+// collect collects LDP, the forwarding table and VPLS independently, an error
+// in one of them doesn't hide the others
+func (c *mplsCollector) collect(ctx *collectorContext) error {
+	var result error
+	for _, f := range []func(*collectorContext) error{c.collectLDPNeighbors, c.collectForwardingTable, c.collectVPLS} {
+		err := f(ctx)
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
+func (c *mplsCollector) fetch(ctx *collectorContext, path string, props []string) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run(path, "=.proplist="+strings.Join(props, ","))
+	if err != nil {
+		c.logError(ctx, path, err)
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *mplsCollector) logError(ctx *collectorContext, path string, err error) {
+	log.WithFields(log.Fields{
+		"device": ctx.device.Name,
+		"path":   path,
+		"error":  err,
+	}).Error("error fetching mpls metrics")
+}
+
+func (c *mplsCollector) collectLDPNeighbors(ctx *collectorContext) error {
+	stats, err := c.fetch(ctx, "/mpls/ldp/neighbor/print", c.ldpProps)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		if re.Map["disabled"] == "true" {
+			continue
+		}
+
+		peer := re.Map["peer"]
+		labels := []string{ctx.device.Name, ctx.device.Address, peer, re.Map["transport"]}
+
+		up := 0.0
+		if re.Map["operational"] == "true" {
+			up = 1
+		}
+		ctx.ch <- prometheus.MustNewConstMetric(c.ldpUpDesc, prometheus.GaugeValue, up, labels...)
+
+		if peer == "" {
+			continue
+		}
+		bindings, err := c.countBindings(peer, ctx)
+		if err != nil {
+			return err
+		}
+		ctx.ch <- prometheus.MustNewConstMetric(c.ldpBindingsDesc, prometheus.GaugeValue, bindings, labels...)
+	}
+
+	return nil
+}
+
+func (c *mplsCollector) countBindings(peer string, ctx *collectorContext) (float64, error) {
+	path := "/mpls/remote-bindings/print"
+	if ctx.caps.v7() {
+		path = "/mpls/ldp/remote-mapping/print"
+	}
+
+	return c.count(ctx, path, "?peer="+peer)
+}
+
+func (c *mplsCollector) collectForwardingTable(ctx *collectorContext) error {
+	v, err := c.count(ctx, "/mpls/forwarding-table/print")
+	if err != nil {
+		return err
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.forwardingDesc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address)
+	return nil
+}
+
+func (c *mplsCollector) count(ctx *collectorContext, path string, query ...string) (float64, error) {
+	sentence := append([]string{path}, query...)
+	reply, err := ctx.client.Run(append(sentence, "=count-only=")...)
+	if err != nil {
+		c.logError(ctx, path, err)
+		return 0, err
+	}
+	if reply.Done.Map["ret"] == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(reply.Done.Map["ret"], 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"path":   path,
+			"error":  err,
+		}).Error("error parsing mpls metrics")
+		return 0, err
+	}
+
+	return v, nil
+}
+
+func (c *mplsCollector) collectVPLS(ctx *collectorContext) error {
+	stats, err := c.fetch(ctx, "/interface/vpls/print", c.vplsProps)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		if re.Map["disabled"] == "true" {
+			continue
+		}
+
+		monitor, err := c.monitorVPLS(re.Map["name"], ctx)
+		if err != nil {
+			return err
+		}
+
+		c.collectForVPLS(re, monitor, ctx)
+	}
+
+	return nil
+}
+
+// monitorVPLS returns the labels signalled for a tunnel, they are reported by
+// monitor only. Each tunnel is monitored on its own as the replies for
+// several tunnels don't carry their names.
+func (c *mplsCollector) monitorVPLS(name string, ctx *collectorContext) (*proto.Sentence, error) {
+	const path = "/interface/vpls/monitor"
+	reply, err := ctx.client.Run(path, "=numbers="+name, "=once=", "=.proplist=local-label,remote-label")
+	if err != nil {
+		c.logError(ctx, path, err)
+		return nil, err
+	}
+
+	if len(reply.Re) == 0 {
+		return nil, nil
+	}
+
+	return reply.Re[0], nil
+}
+
+func (c *mplsCollector) collectForVPLS(re, monitor *proto.Sentence, ctx *collectorContext) {
+	remote := re.Map["remote-peer"]
+	if remote == "" {
+		remote = re.Map["peer"]
+	}
+	labels := []string{ctx.device.Name, ctx.device.Address, re.Map["name"], remote, re.Map["vpls-id"]}
+
+	running := 0.0
+	if re.Map["running"] == "true" {
+		running = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.vplsRunningDesc, prometheus.GaugeValue, running, labels...)
+
+	localLabel, remoteLabel := "", ""
+	if monitor != nil {
+		localLabel, remoteLabel = monitor.Map["local-label"], monitor.Map["remote-label"]
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.vplsInfoDesc, prometheus.GaugeValue, 1, append(labels, localLabel, remoteLabel)...)
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+// fakeTransport replies to the sentences it knows, joined by spaces
+type fakeTransport map[string][]map[string]string
+
+func (f fakeTransport) Run(sentence ...string) (*routeros.Reply, error) {
+	res, ok := f[strings.Join(sentence, " ")]
+	if !ok {
+		return nil, fmt.Errorf("unexpected sentence %v", sentence)
+	}
+
+	reply := &routeros.Reply{Done: &proto.Sentence{Map: map[string]string{}}}
+	for _, m := range res {
+		reply.Re = append(reply.Re, &proto.Sentence{Map: m})
+	}
+
+	return reply, nil
+}
+
+func (f fakeTransport) Close() {}
+
+func TestMPLSCollectorVPLSLabels(t *testing.T) {
+	c := newMPLSCollector().(*mplsCollector)
+	client := fakeTransport{
+		"/interface/vpls/print =.proplist=name,remote-peer,peer,vpls-id,running,disabled": {
+			{"name": "vpls1", "remote-peer": "10.0.0.1", "vpls-id": "1:1", "running": "true", "disabled": "false"},
+			{"name": "vpls2", "remote-peer": "10.0.0.2", "vpls-id": "1:2", "running": "true", "disabled": "false"},
+		},
+		"/interface/vpls/monitor =numbers=vpls1 =once= =.proplist=local-label,remote-label": {
+			{"local-label": "16", "remote-label": "20"},
+		},
+		"/interface/vpls/monitor =numbers=vpls2 =once= =.proplist=local-label,remote-label": {
+			{"local-label": "17", "remote-label": "21"},
+		},
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	err := c.collectVPLS(&collectorContext{ch: ch, device: &config.Device{Name: "pe1", Address: "10.0.0.254"}, client: client})
+	close(ch)
+	assert.NoError(t, err)
+
+	labels := []map[string]string{}
+	for m := range ch {
+		if m.Desc() != c.vplsInfoDesc {
+			continue
+		}
+
+		pb := &dto.Metric{}
+		assert.NoError(t, m.Write(pb))
+		l := make(map[string]string)
+		for _, p := range pb.GetLabel() {
+			l[p.GetName()] = p.GetValue()
+		}
+		labels = append(labels, l)
+	}
+
+	assert.Len(t, labels, 2)
+	for _, l := range labels {
+		switch l["interface"] {
+		case "vpls1":
+			assert.Equal(t, []string{"16", "20"}, []string{l["local_label"], l["remote_label"]})
+		case "vpls2":
+			assert.Equal(t, []string{"17", "21"}, []string{l["local_label"], l["remote_label"]})
+		default:
+			t.Errorf("unexpected tunnel %v", l)
+		}
+	}
+}
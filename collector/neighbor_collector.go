@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type neighborCollector struct {
+	props      []string
+	infoDesc   *prometheus.Desc
+	ageDesc    *prometheus.Desc
+	interfaces *regexp.Regexp
+	protocols  map[string]bool
+	limit      int
+}
+
+func newNeighborCollector(cfg config.Neighbor) routerOSCollector {
+	c := &neighborCollector{
+		limit:     cfg.Limit,
+		protocols: make(map[string]bool),
+	}
+
+	if cfg.Interfaces != "" {
+		re, err := regexp.Compile(cfg.Interfaces)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"interfaces": cfg.Interfaces,
+				"error":      err,
+			}).Fatal("invalid neighbor interface filter")
+		}
+		c.interfaces = re
+	}
+
+	for _, p := range cfg.Protocols {
+		c.protocols[strings.ToLower(p)] = true
+	}
+
+	c.init()
+	return c
+}
+
+func (c *neighborCollector) init() {
+	c.props = []string{"interface", "identity", "mac-address", "address", "address4", "interface-name", "platform", "board", "version", "discovered-by", "age"}
+
+	infoLabels := []string{"name", "address", "interface", "identity", "mac_address", "neighbor_address", "remote_interface", "platform", "board", "version", "protocol"}
+	c.infoDesc = description("neighbor", "info", "neighbor discovered via MNDP, CDP or LLDP", infoLabels)
+
+	ageLabels := []string{"name", "address", "interface", "identity", "mac_address", "neighbor_address", "protocol"}
+	c.ageDesc = description("neighbor", "age_seconds", "time since the last discovery packet of the neighbor was received", ageLabels)
+}
+
+func (c *neighborCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.infoDesc
+	ch <- c.ageDesc
+}
+
+func (c *neighborCollector) collect(ctx *collectorContext) error {
+	stats, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, re := range stats {
+		if !c.matches(re) {
+			continue
+		}
+
+		if c.limit > 0 && count >= c.limit {
+			log.WithFields(log.Fields{
+				"device": ctx.device.Name,
+				"limit":  c.limit,
+			}).Warn("neighbor limit reached, skipping remaining neighbors")
+			break
+		}
+		count++
+
+		c.collectForStat(re, ctx)
+	}
+
+	return nil
+}
+
+func (c *neighborCollector) fetch(ctx *collectorContext) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run("/ip/neighbor/print", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching neighbor metrics")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *neighborCollector) matches(re *proto.Sentence) bool {
+	if c.interfaces != nil && !c.interfaces.MatchString(re.Map["interface"]) {
+		return false
+	}
+
+	if len(c.protocols) == 0 {
+		return true
+	}
+
+	for _, p := range strings.Split(re.Map["discovered-by"], ",") {
+		if c.protocols[strings.TrimSpace(p)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *neighborCollector) collectForStat(re *proto.Sentence, ctx *collectorContext) {
+	iface := re.Map["interface"]
+	identity := re.Map["identity"]
+	mac := re.Map["mac-address"]
+
+	addr := re.Map["address4"]
+	if addr == "" {
+		addr = re.Map["address"]
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1,
+		ctx.device.Name, ctx.device.Address, iface, identity, mac, addr, re.Map["interface-name"],
+		re.Map["platform"], re.Map["board"], re.Map["version"], re.Map["discovered-by"])
+
+	if v := re.Map["age"]; v != "" {
+		age, err := parseDuration(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":    ctx.device.Name,
+				"interface": iface,
+				"value":     v,
+				"error":     err,
+			}).Error("error parsing neighbor age")
+			return
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.ageDesc, prometheus.GaugeValue, age,
+			ctx.device.Name, ctx.device.Address, iface, identity, mac, addr, re.Map["discovered-by"])
+	}
+}
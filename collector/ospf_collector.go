@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+// LSA types of OSPFv2 and v3 as named by RouterOS 6 and 7, AS scoped ones
+// don't belong to an area
+var (
+	areaLSATypes = []string{"router", "network", "summary-network", "summary-asbr", "nssa-external", "nssa",
+		"inter-area-prefix", "inter-area-router", "intra-area-prefix", "link", "opaque-link", "opaque-area"}
+	asLSATypes = []string{"as-external", "external", "opaque-as"}
+)
+
+type ospfCollector struct {
+	instanceProps    []string
+	areaProps        []string
+	neighborProps    []string
+	instanceUpDesc   *prometheus.Desc
+	instanceInfoDesc *prometheus.Desc
+	areaUpDesc       *prometheus.Desc
+	areaInfoDesc     *prometheus.Desc
+	stateDesc        *prometheus.Desc
+	stateChangesDesc *prometheus.Desc
+	adjacencyDesc    *prometheus.Desc
+	neighborInfoDesc *prometheus.Desc
+	lsaCountDesc     *prometheus.Desc
+}
+
+func newOSPFCollector() routerOSCollector {
+	c := &ospfCollector{}
+	c.init()
+	return c
+}
+
+func (c *ospfCollector) init() {
+	c.instanceProps = []string{"name", "router-id", "version", "vrf", "disabled", "inactive", "invalid"}
+	c.areaProps = []string{"name", "instance", "area-id", "type", "disabled", "inactive", "invalid"}
+	// v6 reports the interface, v7 the area of a neighbor
+	c.neighborProps = []string{"instance", "router-id", "address", "interface", "area", "state", "state-changes", "adjacency"}
+
+	const prefix = "ospf"
+	instanceLabels := []string{"name", "address", "instance"}
+	c.instanceUpDesc = description(prefix, "instance_up", "OSPF instance is active (active = 1)", instanceLabels)
+	c.instanceInfoDesc = description(prefix, "instance_info", "OSPF instance details", append(instanceLabels, "router_id", "version", "vrf"))
+
+	areaLabels := []string{"name", "address", "instance", "area"}
+	c.areaUpDesc = description(prefix, "area_up", "OSPF area is active (active = 1)", areaLabels)
+	c.areaInfoDesc = description(prefix, "area_info", "OSPF area details", append(areaLabels, "area_id", "type"))
+
+	labelNames := []string{"name", "address", "instance", "router_id", "neighbor_address"}
+	c.stateDesc = description(prefix, "neighbor_state", "OSPF neighbor state (Full = 1)", labelNames)
+	c.stateChangesDesc = description(prefix, "neighbor_state_changes", "number of OSPF neighbor state changes", labelNames)
+	c.adjacencyDesc = description(prefix, "neighbor_adjacency_seconds", "time since the OSPF adjacency was established", labelNames)
+	c.neighborInfoDesc = description(prefix, "neighbor_info", "OSPF neighbor details", append(labelNames, "area", "interface", "state"))
+	c.lsaCountDesc = description(prefix, "lsa_count", "number of LSAs in the OSPF database", []string{"name", "address", "instance", "area", "type"})
+}
+
+func (c *ospfCollector) requires() requirements {
+	return requirements{minVersion: 6, v6Packages: []string{"routing"}}
+}
+
+func (c *ospfCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.instanceUpDesc
+	ch <- c.instanceInfoDesc
+	ch <- c.areaUpDesc
+	ch <- c.areaInfoDesc
+	ch <- c.stateDesc
+	ch <- c.stateChangesDesc
+	ch <- c.adjacencyDesc
+	ch <- c.neighborInfoDesc
+	ch <- c.lsaCountDesc
+}
+
+// collect collects instances, areas, neighbors and LSAs independently, an
+// error in one of them doesn't hide the others
+func (c *ospfCollector) collect(ctx *collectorContext) error {
+	// v6 and v7 share the paths, the properties differ in the area and
+	// interface only
+	errs := []error{c.collectInstances(ctx)}
+
+	areas, err := c.collectAreas(ctx)
+	errs = append(errs, err, c.collectNeighbors(ctx))
+	if err == nil {
+		errs = append(errs, c.collectLSACounts(areas, ctx))
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ospfCollector) fetch(ctx *collectorContext, path string, props []string) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run(path, "=.proplist="+strings.Join(props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"path":   path,
+			"error":  err,
+		}).Error("error fetching ospf metrics")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *ospfCollector) collectInstances(ctx *collectorContext) error {
+	stats, err := c.fetch(ctx, "/routing/ospf/instance/print", c.instanceProps)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		if re.Map["disabled"] == "true" {
+			continue
+		}
+
+		labels := []string{ctx.device.Name, ctx.device.Address, re.Map["name"]}
+		ctx.ch <- prometheus.MustNewConstMetric(c.instanceUpDesc, prometheus.GaugeValue, c.active(re), labels...)
+		ctx.ch <- prometheus.MustNewConstMetric(c.instanceInfoDesc, prometheus.GaugeValue, 1, append(labels, re.Map["router-id"], re.Map["version"], re.Map["vrf"])...)
+	}
+
+	return nil
+}
+
+// collectAreas returns the enabled areas
+func (c *ospfCollector) collectAreas(ctx *collectorContext) ([]*proto.Sentence, error) {
+	stats, err := c.fetch(ctx, "/routing/ospf/area/print", c.areaProps)
+	if err != nil {
+		return nil, err
+	}
+
+	areas := make([]*proto.Sentence, 0, len(stats))
+	for _, re := range stats {
+		if re.Map["disabled"] == "true" {
+			continue
+		}
+		areas = append(areas, re)
+
+		labels := []string{ctx.device.Name, ctx.device.Address, re.Map["instance"], re.Map["name"]}
+		ctx.ch <- prometheus.MustNewConstMetric(c.areaUpDesc, prometheus.GaugeValue, c.active(re), labels...)
+		ctx.ch <- prometheus.MustNewConstMetric(c.areaInfoDesc, prometheus.GaugeValue, 1, append(labels, re.Map["area-id"], re.Map["type"])...)
+	}
+
+	return areas, nil
+}
+
+// active reports whether an instance or area is in use, v6 flags them as
+// invalid and v7 as inactive otherwise
+func (c *ospfCollector) active(re *proto.Sentence) float64 {
+	if re.Map["inactive"] == "true" || re.Map["invalid"] == "true" {
+		return 0
+	}
+
+	return 1
+}
+
+func (c *ospfCollector) collectNeighbors(ctx *collectorContext) error {
+	stats, err := c.fetch(ctx, "/routing/ospf/neighbor/print", c.neighborProps)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		c.collectForNeighbor(re, ctx)
+	}
+
+	return nil
+}
+
+func (c *ospfCollector) collectForNeighbor(re *proto.Sentence, ctx *collectorContext) {
+	labels := []string{ctx.device.Name, ctx.device.Address, re.Map["instance"], re.Map["router-id"], re.Map["address"]}
+	state := re.Map["state"]
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.neighborInfoDesc, prometheus.GaugeValue, 1, append(labels, re.Map["area"], re.Map["interface"], state)...)
+
+	full := 0.0
+	if strings.EqualFold(state, "full") {
+		full = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.stateDesc, prometheus.GaugeValue, full, labels...)
+
+	if v := re.Map["state-changes"]; v != "" {
+		changes, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"neighbor": re.Map["router-id"],
+				"value":    v,
+				"error":    err,
+			}).Error("error parsing ospf neighbor state changes")
+		} else {
+			ctx.ch <- prometheus.MustNewConstMetric(c.stateChangesDesc, prometheus.CounterValue, changes, labels...)
+		}
+	}
+
+	if v := re.Map["adjacency"]; v != "" {
+		adjacency, err := parseDuration(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"neighbor": re.Map["router-id"],
+				"value":    v,
+				"error":    err,
+			}).Error("error parsing ospf neighbor adjacency")
+		} else {
+			ctx.ch <- prometheus.MustNewConstMetric(c.adjacencyDesc, prometheus.GaugeValue, adjacency, labels...)
+		}
+	}
+}
+
+// collectLSACounts counts the LSAs by type per area, and per instance for AS
+// scoped ones, with count-only queries instead of reading the database
+func (c *ospfCollector) collectLSACounts(areas []*proto.Sentence, ctx *collectorContext) error {
+	instances := make(map[string]bool)
+	for _, re := range areas {
+		instance := re.Map["instance"]
+		instances[instance] = true
+
+		for _, t := range areaLSATypes {
+			err := c.countLSAs(ctx, instance, re.Map["name"], t, "?area="+re.Map["name"])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for instance := range instances {
+		for _, t := range asLSATypes {
+			err := c.countLSAs(ctx, instance, "", t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *ospfCollector) countLSAs(ctx *collectorContext, instance, area, lsaType string, query ...string) error {
+	const path = "/routing/ospf/lsa/print"
+	sentence := append([]string{path, "?instance=" + instance, "?type=" + lsaType}, query...)
+	reply, err := ctx.client.Run(append(sentence, "=count-only=")...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"path":   path,
+			"error":  err,
+		}).Error("error fetching ospf metrics")
+		return err
+	}
+
+	// types not used by the OSPF version of the instance are left out
+	if reply.Done.Map["ret"] == "" || reply.Done.Map["ret"] == "0" {
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(reply.Done.Map["ret"], 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"path":   path,
+			"error":  err,
+		}).Error("error parsing ospf metrics")
+		return err
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.lsaCountDesc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, instance, area, lsaType)
+	return nil
+}
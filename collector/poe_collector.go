@@ -28,6 +28,10 @@ func newPOECollector() routerOSCollector {
 	}
 }
 
+func (c *poeCollector) requires() requirements {
+	return requirements{poe: true}
+}
+
 func (c *poeCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.currentDesc
 	ch <- c.powerDesc
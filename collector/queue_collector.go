@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type queueCollector struct {
+	props       []string
+	simpleProps []string
+	treeProps   []string
+	simpleDescs map[string]*prometheus.Desc
+	treeDescs   map[string]*prometheus.Desc
+}
+
+func newQueueCollector() routerOSCollector {
+	c := &queueCollector{}
+	c.init()
+	return c
+}
+
+func (c *queueCollector) init() {
+	c.props = []string{"bytes", "packets", "dropped", "queued-bytes", "queued-packets", "rate", "packet-rate"}
+	c.simpleProps = append([]string{"name", "target", "parent", "comment"}, c.props...)
+	c.treeProps = append([]string{"name", "parent", "comment"}, c.props...)
+
+	simpleLabels := []string{"name", "address", "queue", "target", "parent", "comment", "direction"}
+	treeLabels := []string{"name", "address", "queue", "parent", "comment"}
+	c.simpleDescs = make(map[string]*prometheus.Desc)
+	c.treeDescs = make(map[string]*prometheus.Desc)
+	for _, p := range c.props {
+		c.simpleDescs[p] = descriptionForPropertyName("queue_simple", p, simpleLabels)
+		c.treeDescs[p] = descriptionForPropertyName("queue_tree", p, treeLabels)
+	}
+}
+
+func (c *queueCollector) requires() requirements {
+	return requirements{minVersion: 6}
+}
+
+func (c *queueCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.simpleDescs {
+		ch <- d
+	}
+	for _, d := range c.treeDescs {
+		ch <- d
+	}
+}
+
+func (c *queueCollector) collect(ctx *collectorContext) error {
+	stats, err := c.fetch("/queue/simple/print", c.simpleProps, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		c.collectForSimpleQueue(re, ctx)
+	}
+
+	stats, err = c.fetch("/queue/tree/print", c.treeProps, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range stats {
+		c.collectForQueueTree(re, ctx)
+	}
+
+	return nil
+}
+
+func (c *queueCollector) fetch(path string, props []string, ctx *collectorContext) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run(path, "?disabled=false", "=.proplist="+strings.Join(props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"path":   path,
+			"error":  err,
+		}).Error("error fetching queue metrics")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *queueCollector) collectForSimpleQueue(re *proto.Sentence, ctx *collectorContext) {
+	uploadLabels := []string{ctx.device.Name, ctx.device.Address, re.Map["name"], re.Map["target"], re.Map["parent"], re.Map["comment"], "upload"}
+	downloadLabels := []string{ctx.device.Name, ctx.device.Address, re.Map["name"], re.Map["target"], re.Map["parent"], re.Map["comment"], "download"}
+
+	for _, p := range c.props {
+		value := re.Map[p]
+		if value == "" {
+			continue
+		}
+
+		up, down, err := splitStringToFloatsBySeparator(value, "/")
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"queue":    re.Map["name"],
+				"property": p,
+				"value":    value,
+				"error":    err,
+			}).Error("error parsing simple queue metric value")
+			continue
+		}
+
+		desc := c.simpleDescs[p]
+		vtype := c.valueType(p)
+		ctx.ch <- prometheus.MustNewConstMetric(desc, vtype, up, uploadLabels...)
+		ctx.ch <- prometheus.MustNewConstMetric(desc, vtype, down, downloadLabels...)
+	}
+}
+
+func (c *queueCollector) collectForQueueTree(re *proto.Sentence, ctx *collectorContext) {
+	for _, p := range c.props {
+		value := re.Map[p]
+		if value == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"queue":    re.Map["name"],
+				"property": p,
+				"value":    value,
+				"error":    err,
+			}).Error("error parsing queue tree metric value")
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.treeDescs[p], c.valueType(p), v, ctx.device.Name, ctx.device.Address, re.Map["name"], re.Map["parent"], re.Map["comment"])
+	}
+}
+
+func (c *queueCollector) valueType(property string) prometheus.ValueType {
+	switch property {
+	case "bytes", "packets", "dropped":
+		return prometheus.CounterValue
+	}
+
+	return prometheus.GaugeValue
+}
@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+const restPort = "443"
+
+// restTransport runs API sentences via the RouterOS v7 REST API. Every
+// sentence is sent as POST to https://router/rest/<command path> with the
+// attributes and queries as JSON body.
+type restTransport struct {
+	baseURL  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// restError is the body returned by the REST API on failed requests
+type restError struct {
+	Error   int    `json:"error"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+}
+
+func (c *collector) connectREST(d *config.Device) (transport, error) {
+	if d.Port == "" {
+		d.Port = restPort
+	}
+
+	t := &restTransport{
+		baseURL:  "https://" + net.JoinHostPort(d.Address, d.Port) + "/rest",
+		user:     d.User,
+		password: d.Password,
+		client: &http.Client{
+			Timeout: c.timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: c.insecureTLS},
+			},
+		},
+	}
+
+	// check the credentials right away like the API login does
+	log.WithField("device", d.Name).Debug("trying to login via REST")
+	if _, err := t.Run("/system/identity/print"); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Run implements the transport interface
+func (t *restTransport) Run(sentence ...string) (*routeros.Reply, error) {
+	if len(sentence) == 0 {
+		return nil, fmt.Errorf("empty sentence")
+	}
+
+	body, err := json.Marshal(restRequestBody(sentence[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.baseURL+sentence[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(t.user, t.password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mikrotik-exporter")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, restErrorFor(resp, b)
+	}
+
+	return parseRESTReply(b)
+}
+
+// Close implements the transport interface
+func (t *restTransport) Close() {
+	t.client.CloseIdleConnections()
+}
+
+// restRequestBody converts the API words of a sentence to the JSON body of a
+// REST request
+func restRequestBody(words []string) map[string]interface{} {
+	body := make(map[string]interface{})
+	queries := []string{}
+
+	for _, w := range words {
+		switch {
+		case strings.HasPrefix(w, "?"):
+			queries = append(queries, w[1:])
+		case strings.HasPrefix(w, "="):
+			kv := strings.SplitN(w[1:], "=", 2)
+			v := ""
+			if len(kv) == 2 {
+				v = kv[1]
+			}
+			if kv[0] == ".proplist" {
+				body[kv[0]] = strings.Split(v, ",")
+			} else {
+				body[kv[0]] = v
+			}
+		}
+	}
+
+	if len(queries) > 0 {
+		body[".query"] = queries
+	}
+
+	return body
+}
+
+// parseRESTReply converts the JSON response to the sentences the binary API
+// would have returned. Lists of items become !re sentences, a single "ret"
+// value (e.g. of count-only) is returned in the !done sentence.
+func parseRESTReply(b []byte) (*routeros.Reply, error) {
+	reply := &routeros.Reply{Done: &proto.Sentence{Word: "!done", Map: make(map[string]string)}}
+
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return reply, nil
+	}
+
+	var items []map[string]interface{}
+	if b[0] == '[' {
+		if err := json.Unmarshal(b, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		var item map[string]interface{}
+		if err := json.Unmarshal(b, &item); err != nil {
+			return nil, err
+		}
+
+		if ret, ok := item["ret"]; ok && len(item) == 1 {
+			reply.Done.Map["ret"] = restValue(ret)
+			reply.Done.List = append(reply.Done.List, proto.Pair{Key: "ret", Value: reply.Done.Map["ret"]})
+			return reply, nil
+		}
+		items = append(items, item)
+	}
+
+	for _, item := range items {
+		sen := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+		for k, v := range item {
+			sen.Map[k] = restValue(v)
+			sen.List = append(sen.List, proto.Pair{Key: k, Value: sen.Map[k]})
+		}
+		reply.Re = append(reply.Re, sen)
+	}
+
+	return reply, nil
+}
+
+func restValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// restErrorFor returns a routeros.DeviceError for errors reported by the
+// router, so callers can tell them apart from connection problems
+func restErrorFor(resp *http.Response, b []byte) error {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("REST: authentication failed")
+	}
+
+	e := restError{}
+	if err := json.Unmarshal(b, &e); err != nil || (e.Message == "" && e.Detail == "") {
+		if len(b) > 512 {
+			b = b[:512]
+		}
+		return fmt.Errorf("REST: server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+
+	msg := e.Message
+	if e.Detail != "" {
+		msg = e.Detail
+	}
+
+	return &routeros.DeviceError{Sentence: &proto.Sentence{
+		Word: "!trap",
+		Map:  map[string]string{"message": msg},
+	}}
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/stretchr/testify/assert"
+	routeros "gopkg.in/routeros.v2"
+)
+
+func TestRESTRequestBody(t *testing.T) {
+	body := restRequestBody([]string{"=.proplist=name,type", "?disabled=false", "=count-only="})
+
+	assert.Equal(t, map[string]interface{}{
+		".proplist":  []string{"name", "type"},
+		".query":     []string{"disabled=false"},
+		"count-only": "",
+	}, body)
+}
+
+func TestRESTTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "foo" || password != "bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/rest/system/identity/print":
+			_, _ = w.Write([]byte(`[{"name":"router1"}]`))
+		case "/rest/interface/print":
+			body := map[string]interface{}{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if _, ok := body["count-only"]; ok {
+				_, _ = w.Write([]byte(`{"ret":"2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{".id":"*1","name":"ether1","running":true},{".id":"*2","name":"ether2","running":false}]`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":400,"message":"Bad Request","detail":"no such command"}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := newCollector(&config.Config{}, WithTLS(true))
+	d := &config.Device{Name: "router1", Address: u.Hostname(), Port: u.Port(), User: "foo", Password: "bar", Transport: config.TransportREST}
+
+	cl, err := c.connect(d)
+	assert.NoError(t, err)
+	defer cl.Close()
+
+	reply, err := cl.Run("/interface/print", "=.proplist=name,running")
+	assert.NoError(t, err)
+	assert.Len(t, reply.Re, 2)
+	assert.Equal(t, "ether1", reply.Re[0].Map["name"])
+	assert.Equal(t, "true", reply.Re[0].Map["running"])
+
+	reply, err = cl.Run("/interface/print", "=count-only=")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", reply.Done.Map["ret"])
+
+	_, err = cl.Run("/routing/bgp/peer/print")
+	assert.IsType(t, &routeros.DeviceError{}, err)
+	assert.Contains(t, err.Error(), "no such command")
+
+	d.Password = "wrong"
+	_, err = c.connect(d)
+	assert.Error(t, err)
+}
@@ -0,0 +1,507 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/gosnmp/gosnmp"
+	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+const snmpPort = 161
+
+// OIDs of IF-MIB, HOST-RESOURCES-MIB and MIKROTIK-MIB
+const (
+	oidSysDescr    = "1.3.6.1.2.1.1.1.0"
+	oidSysUpTime   = "1.3.6.1.2.1.1.3.0"
+	oidSysName     = "1.3.6.1.2.1.1.5.0"
+	oidIfTable     = "1.3.6.1.2.1.2.2.1"
+	oidIfXTable    = "1.3.6.1.2.1.31.1.1.1"
+	oidHrUptime    = "1.3.6.1.2.1.25.1.1.0"
+	oidHrStorage   = "1.3.6.1.2.1.25.2.3.1"
+	oidHrProcLoad  = "1.3.6.1.2.1.25.3.3.1.2"
+	oidMtxrVersion = "1.3.6.1.4.1.14988.1.1.4.4.0"
+	oidMtxrBoard   = "1.3.6.1.4.1.14988.1.1.7.8.0"
+	oidMtxrVoltage = "1.3.6.1.4.1.14988.1.1.3.8.0"
+	oidMtxrTemp    = "1.3.6.1.4.1.14988.1.1.3.10.0"
+	oidMtxrCPUTemp = "1.3.6.1.4.1.14988.1.1.3.11.0"
+	oidMtxrGauge   = "1.3.6.1.4.1.14988.1.1.3.100.1"
+)
+
+// ifTableColumns maps the IF-MIB columns to interface properties. The 64 bit
+// counters of ifXTable take precedence over the ones of ifTable.
+var ifTableColumns = []struct {
+	oid      string
+	property string
+}{
+	{oidIfTable + ".2", "name"},
+	{oidIfTable + ".3", "type"},
+	{oidIfTable + ".4", "actual-mtu"},
+	{oidIfTable + ".7", "disabled"},
+	{oidIfTable + ".8", "running"},
+	{oidIfTable + ".10", "rx-byte"},
+	{oidIfTable + ".13", "rx-drop"},
+	{oidIfTable + ".14", "rx-error"},
+	{oidIfTable + ".16", "tx-byte"},
+	{oidIfTable + ".19", "tx-drop"},
+	{oidIfTable + ".20", "tx-error"},
+	{oidIfXTable + ".1", "name"},
+	{oidIfXTable + ".6", "rx-byte"},
+	{oidIfXTable + ".7", "rx-packet"},
+	{oidIfXTable + ".8", "rx-packet"},
+	{oidIfXTable + ".9", "rx-packet"},
+	{oidIfXTable + ".10", "tx-byte"},
+	{oidIfXTable + ".11", "tx-packet"},
+	{oidIfXTable + ".12", "tx-packet"},
+	{oidIfXTable + ".13", "tx-packet"},
+	{oidIfXTable + ".18", "comment"},
+}
+
+// ifTypes maps IANA interface types to RouterOS interface types
+var ifTypes = map[int64]string{
+	6:   "ether",
+	23:  "ppp",
+	24:  "loopback",
+	53:  "virtual",
+	71:  "wlan",
+	131: "tunnel",
+	135: "vlan",
+	209: "bridge",
+}
+
+// snmpTransport answers the sentences of the interface, resource and health
+// collectors from IF-MIB, HOST-RESOURCES-MIB and MIKROTIK-MIB, producing the
+// same properties as the API
+type snmpTransport struct {
+	snmp *gosnmp.GoSNMP
+}
+
+func (c *collector) connectSNMP(d *config.Device) (transport, error) {
+	port := uint16(snmpPort)
+	if d.Port != "" {
+		p, err := strconv.ParseUint(d.Port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", d.Port)
+		}
+		port = uint16(p)
+	}
+
+	g := &gosnmp.GoSNMP{
+		Target:             d.Address,
+		Port:               port,
+		Transport:          "udp",
+		Timeout:            c.timeout,
+		Retries:            1,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     25,
+		ExponentialTimeout: false,
+	}
+
+	if d.SNMP.Version == "3" {
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		usm := &gosnmp.UsmSecurityParameters{
+			UserName:                 d.SNMP.Username,
+			AuthenticationProtocol:   snmpAuthProtocol(d.SNMP.AuthProtocol),
+			AuthenticationPassphrase: d.SNMP.AuthPassword,
+			PrivacyProtocol:          snmpPrivProtocol(d.SNMP.PrivProtocol),
+			PrivacyPassphrase:        d.SNMP.PrivPassword,
+		}
+		g.SecurityParameters = usm
+		switch {
+		case usm.PrivacyProtocol != gosnmp.NoPriv:
+			g.MsgFlags = gosnmp.AuthPriv
+		case usm.AuthenticationProtocol != gosnmp.NoAuth:
+			g.MsgFlags = gosnmp.AuthNoPriv
+		default:
+			g.MsgFlags = gosnmp.NoAuthNoPriv
+		}
+	} else {
+		g.Version = gosnmp.Version2c
+		g.Community = d.SNMP.Community
+		if g.Community == "" {
+			g.Community = "public"
+		}
+	}
+
+	log.WithField("device", d.Name).Debug("trying to connect via SNMP")
+	if err := g.Connect(); err != nil {
+		return nil, err
+	}
+
+	t := &snmpTransport{snmp: g}
+
+	// SNMP is connectionless, check that the agent answers
+	if _, err := t.get(oidSysName); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func snmpAuthProtocol(p string) gosnmp.SnmpV3AuthProtocol {
+	switch p {
+	case "MD5":
+		return gosnmp.MD5
+	case "SHA":
+		return gosnmp.SHA
+	case "SHA256":
+		return gosnmp.SHA256
+	case "SHA512":
+		return gosnmp.SHA512
+	}
+
+	return gosnmp.NoAuth
+}
+
+func snmpPrivProtocol(p string) gosnmp.SnmpV3PrivProtocol {
+	switch p {
+	case "DES":
+		return gosnmp.DES
+	case "AES":
+		return gosnmp.AES
+	case "AES256":
+		return gosnmp.AES256
+	}
+
+	return gosnmp.NoPriv
+}
+
+// Run implements the transport interface
+func (t *snmpTransport) Run(sentence ...string) (*routeros.Reply, error) {
+	if len(sentence) == 0 {
+		return nil, fmt.Errorf("empty sentence")
+	}
+
+	var (
+		re  []*proto.Sentence
+		err error
+	)
+
+	switch sentence[0] {
+	case "/interface/print":
+		re, err = t.interfaces()
+	case "/system/resource/print":
+		re, err = t.resource()
+	case "/system/health/print":
+		re, err = t.health()
+	case "/system/identity/print":
+		re, err = t.identity()
+	case "/system/package/print":
+		// packages are not available via SNMP
+	default:
+		return nil, &routeros.DeviceError{Sentence: &proto.Sentence{
+			Word: "!trap",
+			Map:  map[string]string{"message": sentence[0] + " is not available via SNMP"},
+		}}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &routeros.Reply{Re: re, Done: &proto.Sentence{Word: "!done", Map: make(map[string]string)}}
+	for _, w := range sentence[1:] {
+		if w == "=count-only=" {
+			setValue(reply.Done, "ret", strconv.Itoa(len(re)))
+			reply.Re = nil
+		}
+	}
+
+	return reply, nil
+}
+
+// Close implements the transport interface
+func (t *snmpTransport) Close() {
+	if t.snmp.Conn != nil {
+		t.snmp.Conn.Close()
+	}
+}
+
+// supports implements the limitedTransport interface
+func (t *snmpTransport) supports(co routerOSCollector) bool {
+	switch co.(type) {
+	case *interfaceCollector, *resourceCollector, *healthCollector:
+		return true
+	}
+
+	return false
+}
+
+// get returns the values of the given scalar OIDs as strings, missing
+// objects are left out
+func (t *snmpTransport) get(oids ...string) (map[string]string, error) {
+	res, err := t.snmp.Get(oids)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, v := range res.Variables {
+		if s, ok := snmpValue(v); ok {
+			values[strings.TrimPrefix(v.Name, ".")] = s
+		}
+	}
+
+	return values, nil
+}
+
+// walk returns the values of a table column by row index
+func (t *snmpTransport) walk(oid string) (map[string]string, error) {
+	pdus, err := t.snmp.BulkWalkAll(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, v := range pdus {
+		if s, ok := snmpValue(v); ok {
+			values[strings.TrimPrefix(strings.TrimPrefix(v.Name, "."), oid+".")] = s
+		}
+	}
+
+	return values, nil
+}
+
+func snmpValue(v gosnmp.SnmpPDU) (string, bool) {
+	switch v.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return "", false
+	case gosnmp.OctetString:
+		b, _ := v.Value.([]byte)
+		return string(b), true
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		s, _ := v.Value.(string)
+		return s, true
+	}
+
+	return gosnmp.ToBigInt(v.Value).String(), true
+}
+
+func (t *snmpTransport) interfaces() ([]*proto.Sentence, error) {
+	rows := make(map[string]*proto.Sentence)
+	counted := make(map[string]bool)
+
+	for _, col := range ifTableColumns {
+		values, err := t.walk(col.oid)
+		if err != nil {
+			return nil, err
+		}
+
+		for idx, v := range values {
+			re, ok := rows[idx]
+			if !ok {
+				re = &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+				rows[idx] = re
+			}
+
+			switch col.property {
+			case "type":
+				n, _ := strconv.ParseInt(v, 10, 64)
+				if typ, ok := ifTypes[n]; ok {
+					v = typ
+				} else {
+					v = "other"
+				}
+			case "disabled":
+				v = strconv.FormatBool(v == "2")
+			case "running":
+				v = strconv.FormatBool(v == "1")
+			case "rx-packet", "tx-packet":
+				// sum of unicast, multicast and broadcast packets
+				key := idx + col.property
+				if counted[key] {
+					v = addCounters(re.Map[col.property], v)
+				}
+				counted[key] = true
+			}
+
+			setValue(re, col.property, v)
+		}
+	}
+
+	indexes := make([]int, 0, len(rows))
+	for idx := range rows {
+		n, _ := strconv.Atoi(idx)
+		indexes = append(indexes, n)
+	}
+	sort.Ints(indexes)
+
+	res := make([]*proto.Sentence, 0, len(rows))
+	for _, idx := range indexes {
+		res = append(res, rows[strconv.Itoa(idx)])
+	}
+
+	return res, nil
+}
+
+func addCounters(a, b string) string {
+	x, _ := strconv.ParseUint(a, 10, 64)
+	y, _ := strconv.ParseUint(b, 10, 64)
+
+	return strconv.FormatUint(x+y, 10)
+}
+
+func (t *snmpTransport) resource() ([]*proto.Sentence, error) {
+	re := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+
+	values, err := t.get(oidSysDescr, oidSysUpTime, oidHrUptime, oidMtxrVersion, oidMtxrBoard)
+	if err != nil {
+		return nil, err
+	}
+
+	uptime, ok := values[oidHrUptime]
+	if !ok {
+		uptime = values[oidSysUpTime]
+	}
+	if ticks, err := strconv.ParseInt(uptime, 10, 64); err == nil {
+		setValue(re, "uptime", formatUptime(time.Duration(ticks)*10*time.Millisecond))
+	}
+
+	board, ok := values[oidMtxrBoard]
+	if !ok {
+		board = strings.TrimPrefix(values[oidSysDescr], "RouterOS ")
+	}
+	setValue(re, "board-name", board)
+	setValue(re, "version", values[oidMtxrVersion])
+
+	loads, err := t.walk(oidHrProcLoad)
+	if err != nil {
+		return nil, err
+	}
+	if len(loads) > 0 {
+		sum := 0
+		for _, l := range loads {
+			n, _ := strconv.Atoi(l)
+			sum += n
+		}
+		setValue(re, "cpu-load", strconv.Itoa(sum/len(loads)))
+	}
+
+	if err := t.storage(re); err != nil {
+		return nil, err
+	}
+
+	return []*proto.Sentence{re}, nil
+}
+
+// storage reads memory and disk usage from hrStorageTable
+func (t *snmpTransport) storage(re *proto.Sentence) error {
+	descr, err := t.walk(oidHrStorage + ".3")
+	if err != nil {
+		return err
+	}
+	units, err := t.walk(oidHrStorage + ".4")
+	if err != nil {
+		return err
+	}
+	size, err := t.walk(oidHrStorage + ".5")
+	if err != nil {
+		return err
+	}
+	used, err := t.walk(oidHrStorage + ".6")
+	if err != nil {
+		return err
+	}
+
+	for idx, d := range descr {
+		var total, free string
+		switch d {
+		case "main memory":
+			total, free = "total-memory", "free-memory"
+		case "system disk":
+			total, free = "total-hdd-space", "free-hdd-space"
+		default:
+			continue
+		}
+
+		u, _ := strconv.ParseUint(units[idx], 10, 64)
+		s, _ := strconv.ParseUint(size[idx], 10, 64)
+		f, _ := strconv.ParseUint(used[idx], 10, 64)
+		setValue(re, total, strconv.FormatUint(s*u, 10))
+		setValue(re, free, strconv.FormatUint((s-f)*u, 10))
+	}
+
+	return nil
+}
+
+func (t *snmpTransport) health() ([]*proto.Sentence, error) {
+	re := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+
+	values, err := t.get(oidMtxrVoltage, oidMtxrTemp, oidMtxrCPUTemp)
+	if err != nil {
+		return nil, err
+	}
+	for oid, p := range map[string]string{oidMtxrVoltage: "voltage", oidMtxrTemp: "temperature", oidMtxrCPUTemp: "cpu-temperature"} {
+		if v, ok := values[oid]; ok {
+			setValue(re, p, deciValue(v))
+		}
+	}
+
+	// RouterOS v7 reports the sensors in a table instead
+	names, err := t.walk(oidMtxrGauge + ".2")
+	if err != nil {
+		return nil, err
+	}
+	gauges, err := t.walk(oidMtxrGauge + ".3")
+	if err != nil {
+		return nil, err
+	}
+	for idx, name := range names {
+		switch name {
+		case "voltage":
+			setValue(re, name, deciValue(gauges[idx]))
+		case "temperature", "cpu-temperature":
+			setValue(re, name, gauges[idx])
+		}
+	}
+
+	return []*proto.Sentence{re}, nil
+}
+
+func (t *snmpTransport) identity() ([]*proto.Sentence, error) {
+	values, err := t.get(oidSysName)
+	if err != nil {
+		return nil, err
+	}
+
+	re := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+	setValue(re, "name", values[oidSysName])
+
+	return []*proto.Sentence{re}, nil
+}
+
+// deciValue converts a value in tenths to its decimal representation
+func deciValue(v string) string {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+
+	return strconv.FormatFloat(n/10, 'f', -1, 64)
+}
+
+// formatUptime renders a duration like RouterOS does, e.g. 1w2d3h4m5s
+func formatUptime(d time.Duration) string {
+	s := int64(d.Seconds())
+	b := &strings.Builder{}
+	for _, u := range []struct {
+		unit    string
+		seconds int64
+	}{{"w", 604800}, {"d", 86400}, {"h", 3600}, {"m", 60}} {
+		if s >= u.seconds {
+			fmt.Fprintf(b, "%d%s", s/u.seconds, u.unit)
+			s %= u.seconds
+		}
+	}
+	fmt.Fprintf(b, "%ds", s)
+
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatUptime(t *testing.T) {
+	for _, d := range []time.Duration{
+		0,
+		42 * time.Second,
+		3*24*time.Hour + 3*time.Hour + 42*time.Minute + 53*time.Second,
+		15*7*24*time.Hour + 5*time.Second,
+	} {
+		s := formatUptime(d)
+		seconds, err := parseUptime(s)
+		assert.NoError(t, err)
+		assert.Equal(t, d.Seconds(), seconds, s)
+	}
+
+	assert.Equal(t, "1w2d3h4m5s", formatUptime(9*24*time.Hour+3*time.Hour+4*time.Minute+5*time.Second))
+}
+
+func TestSNMPValue(t *testing.T) {
+	v, ok := snmpValue(gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("ether1")})
+	assert.True(t, ok)
+	assert.Equal(t, "ether1", v)
+
+	v, ok = snmpValue(gosnmp.SnmpPDU{Type: gosnmp.Counter64, Value: uint64(18446744073709551615)})
+	assert.True(t, ok)
+	assert.Equal(t, "18446744073709551615", v)
+
+	_, ok = snmpValue(gosnmp.SnmpPDU{Type: gosnmp.NoSuchObject})
+	assert.False(t, ok)
+
+	assert.Equal(t, "24.1", deciValue("241"))
+}
+
+func TestSNMPTransportSupports(t *testing.T) {
+	tr := &snmpTransport{}
+
+	assert.True(t, tr.supports(newInterfaceCollector()))
+	assert.True(t, tr.supports(newResourceCollector()))
+	assert.True(t, tr.supports(newhealthCollector()))
+	assert.False(t, tr.supports(newBGPCollector()))
+}
@@ -0,0 +1,474 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+const (
+	sshPort = "22"
+
+	// sshUserSuffix disables colors and terminal detection and sets a wide
+	// terminal so the CLI output isn't wrapped
+	sshUserSuffix = "+ct4096w"
+)
+
+var (
+	cliErrorRegex = regexp.MustCompile(`\(line \d+ column \d+\)|^failure:|^no such item|^input does not match`)
+	cliUnitRegex  = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)(KiB|MiB|GiB|TiB|%|C|V|A|W|mA|mW|dBm|MHz|RPM|bps|kbps|Mbps|Gbps)$`)
+
+	// cliFlags maps the flags of print terse to API properties
+	cliFlags = map[rune]string{
+		'X': "disabled",
+		'D': "dynamic",
+		'R': "running",
+		'A': "active",
+		'I': "invalid",
+	}
+)
+
+// sshTransport runs API sentences as CLI commands via SSH and parses the
+// output into sentences. Values are converted to the API representation as
+// far as possible, so coverage of the collectors is partial.
+type sshTransport struct {
+	conn    net.Conn
+	client  *ssh.Client
+	timeout time.Duration
+}
+
+// cliCommand is the CLI equivalent of an API sentence
+type cliCommand struct {
+	menu      string
+	command   string
+	args      []string
+	where     []string
+	proplist  []string
+	numbers   []string
+	countOnly bool
+}
+
+func (c *collector) connectSSH(d *config.Device) (transport, error) {
+	if d.Port == "" {
+		d.Port = sshPort
+	}
+
+	knownHostsFile := d.SSH.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("SSH: could not read known hosts: %v", err)
+	}
+
+	auth := []ssh.AuthMethod{}
+	if d.SSH.KeyFile != "" {
+		b, err := ioutil.ReadFile(d.SSH.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("SSH: invalid key file %s: %v", d.SSH.KeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if d.Password != "" {
+		auth = append(auth, ssh.Password(d.Password))
+	}
+
+	addr := net.JoinHostPort(d.Address, d.Port)
+	log.WithField("device", d.Name).Debug("trying to Dial via SSH")
+	conn, err := net.DialTimeout("tcp", addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(c.timeout))
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            d.User + sshUserSuffix,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         c.timeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &sshTransport{
+		conn:    conn,
+		client:  ssh.NewClient(sshConn, chans, reqs),
+		timeout: c.timeout,
+	}, nil
+}
+
+// Run implements the transport interface
+func (t *sshTransport) Run(sentence ...string) (*routeros.Reply, error) {
+	cmd, err := parseSentence(sentence)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &routeros.Reply{Done: &proto.Sentence{Word: "!done", Map: make(map[string]string)}}
+
+	if cmd.command != "print" {
+		// monitor and info commands are run once per item as the output of
+		// multiple items is rendered in columns
+		for _, n := range cmd.numbers {
+			out, err := t.exec(cmd.monitorLine(n))
+			if err != nil {
+				return nil, err
+			}
+			sen := parseKeyValues(out)
+			if sen.Map["name"] == "" {
+				setValue(sen, "name", n)
+			}
+			reply.Re = append(reply.Re, sen)
+		}
+
+		return reply, nil
+	}
+
+	if cmd.countOnly {
+		out, err := t.exec(cmd.printLine(false))
+		if err != nil {
+			return nil, err
+		}
+		setValue(reply.Done, "ret", strings.TrimSpace(out))
+		return reply, nil
+	}
+
+	out, err := t.exec(cmd.printLine(true))
+	if _, ok := err.(*routeros.DeviceError); ok {
+		// menus with a single item like /system/resource don't support terse
+		out, err = t.exec(cmd.printLine(false))
+		if err != nil {
+			return nil, err
+		}
+		reply.Re = append(reply.Re, parseKeyValues(out))
+		return reply, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reply.Re = parseTerse(out, cmd.proplist)
+	return reply, nil
+}
+
+// Close implements the transport interface
+func (t *sshTransport) Close() {
+	t.client.Close()
+}
+
+// supports implements the limitedTransport interface
+func (t *sshTransport) supports(co routerOSCollector) bool {
+	switch co.(type) {
+	case *lteCollector, *w60gInterfaceCollector:
+		// their CLI output differs too much from the API
+		return false
+	case *logCollector, *firewallCollector:
+		// print terse doesn't show the ids needed for the cursor and rule labels
+		return false
+	}
+
+	return true
+}
+
+func (t *sshTransport) exec(line string) (string, error) {
+	_ = t.conn.SetDeadline(time.Now().Add(t.timeout))
+
+	session, err := t.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	b, err := session.CombinedOutput(line)
+	out := strings.TrimSpace(strings.Replace(string(b), "\r", "", -1))
+	if cliErrorRegex.MatchString(out) {
+		return "", &routeros.DeviceError{Sentence: &proto.Sentence{
+			Word: "!trap",
+			Map:  map[string]string{"message": out},
+		}}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return out, nil
+}
+
+// parseSentence converts an API sentence to a CLI command
+func parseSentence(sentence []string) (*cliCommand, error) {
+	if len(sentence) == 0 || !strings.HasPrefix(sentence[0], "/") {
+		return nil, fmt.Errorf("invalid sentence %q", sentence)
+	}
+
+	i := strings.LastIndex(sentence[0], "/")
+	cmd := &cliCommand{
+		menu:    "/" + strings.Replace(sentence[0][1:i], "/", " ", -1),
+		command: sentence[0][i+1:],
+	}
+	if cmd.command == "getall" {
+		cmd.command = "print"
+	}
+
+	for _, w := range sentence[1:] {
+		switch {
+		case strings.HasPrefix(w, "?#") || strings.HasPrefix(w, "?-"):
+			return nil, fmt.Errorf("query %q is not supported via SSH", w)
+		case strings.HasPrefix(w, "?"):
+			kv := strings.SplitN(w[1:], "=", 2)
+			if len(kv) == 1 {
+				cmd.where = append(cmd.where, kv[0])
+			} else {
+				cmd.where = append(cmd.where, kv[0]+"="+cliValue(kv[1]))
+			}
+		case strings.HasPrefix(w, "="):
+			kv := strings.SplitN(w[1:], "=", 2)
+			v := ""
+			if len(kv) == 2 {
+				v = kv[1]
+			}
+
+			switch kv[0] {
+			case ".proplist":
+				cmd.proplist = strings.Split(v, ",")
+			case "count-only":
+				cmd.countOnly = true
+			case "once":
+			case "numbers", "number":
+				cmd.numbers = strings.Split(v, ",")
+			case "interface":
+				// monitor-traffic takes the interfaces as items, for other
+				// commands it is a regular argument
+				if cmd.command == "monitor-traffic" {
+					cmd.numbers = strings.Split(v, ",")
+				} else {
+					cmd.args = append(cmd.args, "interface="+cliValue(v))
+				}
+			default:
+				if v == "" {
+					cmd.args = append(cmd.args, kv[0])
+				} else {
+					cmd.args = append(cmd.args, kv[0]+"="+cliValue(v))
+				}
+			}
+		}
+	}
+
+	if cmd.command != "print" && len(cmd.numbers) == 0 {
+		return nil, fmt.Errorf("command %s requires numbers via SSH", cmd.command)
+	}
+
+	return cmd, nil
+}
+
+func (c *cliCommand) printLine(terse bool) string {
+	parts := []string{c.menu, "print"}
+	if terse {
+		parts = append(parts, "terse")
+	}
+	if c.countOnly {
+		parts = append(parts, "count-only")
+	}
+	parts = append(parts, c.args...)
+	if terse && len(c.proplist) > 0 {
+		parts = append(parts, "proplist="+strings.Join(c.proplist, ","))
+	}
+	if len(c.where) > 0 {
+		parts = append(parts, "where", strings.Join(c.where, " and "))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (c *cliCommand) monitorLine(number string) string {
+	parts := []string{c.menu, c.command, cliValue(number)}
+	parts = append(parts, c.args...)
+	parts = append(parts, "once")
+
+	return strings.Join(parts, " ")
+}
+
+// cliValue converts an API value to CLI syntax
+func cliValue(v string) string {
+	switch v {
+	case "true":
+		return "yes"
+	case "false":
+		return "no"
+	}
+
+	if strings.ContainsAny(v, " \t\";") {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+// apiValue converts a value of the CLI output to the API representation
+func apiValue(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+		if u, err := strconv.Unquote(v); err == nil {
+			v = u
+		}
+	}
+
+	switch v {
+	case "yes":
+		return "true"
+	case "no":
+		return "false"
+	}
+
+	m := cliUnitRegex.FindStringSubmatch(v)
+	if m == nil {
+		return v
+	}
+
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return v
+	}
+	switch m[2] {
+	case "KiB":
+		f *= 1 << 10
+	case "MiB":
+		f *= 1 << 20
+	case "GiB":
+		f *= 1 << 30
+	case "TiB":
+		f *= 1 << 40
+	case "kbps":
+		f *= 1e3
+	case "Mbps":
+		f *= 1e6
+	case "Gbps":
+		f *= 1e9
+	default:
+		return m[1]
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func setValue(sen *proto.Sentence, key, value string) {
+	if sen.Map == nil {
+		sen.Map = make(map[string]string)
+	}
+	if _, ok := sen.Map[key]; !ok {
+		sen.List = append(sen.List, proto.Pair{Key: key, Value: value})
+	}
+	sen.Map[key] = value
+}
+
+// parseKeyValues parses the "key: value" output of print and monitor once
+func parseKeyValues(out string) *proto.Sentence {
+	sen := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		setValue(sen, strings.TrimSpace(kv[0]), apiValue(kv[1]))
+	}
+
+	return sen
+}
+
+// parseTerse parses the output of print terse. Flags are converted to their
+// properties, flag properties which were requested but not set are false.
+func parseTerse(out string, proplist []string) []*proto.Sentence {
+	res := []*proto.Sentence{}
+
+	for _, line := range strings.Split(out, "\n") {
+		tokens := splitTerse(line)
+		if len(tokens) == 0 || strings.HasSuffix(tokens[0], ":") {
+			// skip "Flags:" and "Columns:" legends
+			continue
+		}
+
+		sen := &proto.Sentence{Word: "!re", Map: make(map[string]string)}
+		key := ""
+		for _, tok := range tokens {
+			kv := strings.SplitN(tok, "=", 2)
+			switch {
+			case len(kv) == 2:
+				key = kv[0]
+				setValue(sen, key, apiValue(kv[1]))
+			case key != "":
+				// unquoted value containing spaces
+				setValue(sen, key, sen.Map[key]+" "+tok)
+			default:
+				for _, f := range tok {
+					if p, ok := cliFlags[f]; ok {
+						setValue(sen, p, "true")
+					}
+				}
+			}
+		}
+
+		for _, p := range proplist {
+			for _, f := range cliFlags {
+				if p == f && sen.Map[p] == "" {
+					setValue(sen, p, "false")
+				}
+			}
+		}
+
+		res = append(res, sen)
+	}
+
+	return res
+}
+
+// splitTerse splits a line at whitespace outside of quotes
+func splitTerse(line string) []string {
+	tokens := []string{}
+	cur := &strings.Builder{}
+	quoted := false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	return tokens
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSentenceForCLI(t *testing.T) {
+	var testCases = []struct {
+		sentence []string
+		line     string
+		terse    bool
+	}{
+		{[]string{"/interface/print", "=.proplist=name,running"}, "/interface print terse proplist=name,running", true},
+		{[]string{"/ip/ipsec/policy/print", "?disabled=false", "?dynamic=false", "=.proplist=src-address"}, "/ip ipsec policy print terse proplist=src-address where disabled=no and dynamic=no", true},
+		{[]string{"/ip/dhcp-server/lease/print", "?server=dhcp lan", "=active=", "=count-only="}, `/ip dhcp-server lease print count-only active where server="dhcp lan"`, false},
+		{[]string{"/ip/route/print", "?disabled=false", "?bgp", "=count-only="}, "/ip route print count-only where disabled=no and bgp", false},
+		{[]string{"/system/package/getall"}, "/system package print terse", true},
+	}
+
+	for _, testCase := range testCases {
+		cmd, err := parseSentence(testCase.sentence)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.line, cmd.printLine(testCase.terse))
+	}
+
+	cmd, err := parseSentence([]string{"/interface/ethernet/monitor", "=numbers=sfp1,sfp2", "=once=", "=.proplist=name,sfp-temperature"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sfp1", "sfp2"}, cmd.numbers)
+	assert.Equal(t, "/interface ethernet monitor sfp1 once", cmd.monitorLine("sfp1"))
+
+	cmd, err = parseSentence([]string{"/interface/monitor-traffic", "=interface=ether1,ether2", "=once="})
+	assert.NoError(t, err)
+	assert.Equal(t, "/interface monitor-traffic ether2 once", cmd.monitorLine("ether2"))
+
+	cmd, err = parseSentence([]string{"/ip/address/print", "=interface=ether1"})
+	assert.NoError(t, err)
+	assert.Empty(t, cmd.numbers)
+	assert.Equal(t, "/ip address print interface=ether1", cmd.printLine(false))
+
+	_, err = parseSentence([]string{"/interface/print", "?#|"})
+	assert.Error(t, err)
+}
+
+func TestParseTerse(t *testing.T) {
+	out := `Flags: D - dynamic; X - disabled, R - running
+ 0  R name=ether1 type=ether comment="uplink to core" rx-byte=1024
+ 1 X  name=ether2 type=ether rx-byte=0
+ 2 DR name=bridge local type=bridge rx-byte=2048`
+
+	res := parseTerse(out, []string{"name", "type", "running", "disabled"})
+
+	assert.Len(t, res, 3)
+	assert.Equal(t, map[string]string{"name": "ether1", "type": "ether", "comment": "uplink to core", "rx-byte": "1024", "running": "true", "disabled": "false"}, res[0].Map)
+	assert.Equal(t, "true", res[1].Map["disabled"])
+	assert.Equal(t, "false", res[1].Map["running"])
+	assert.Equal(t, "bridge local", res[2].Map["name"])
+	assert.Equal(t, "true", res[2].Map["dynamic"])
+}
+
+func TestParseKeyValues(t *testing.T) {
+	out := `                   uptime: 1w2d3h4m5s
+                  version: 7.12.1 (stable)
+              free-memory: 20.5MiB
+                 cpu-load: 3%
+               board-name: RB4011iGS+
+          sfp-temperature: 37C
+       rx-bits-per-second: 1203.4kbps
+               write-sect-since-reboot: no`
+
+	sen := parseKeyValues(out)
+
+	assert.Equal(t, "1w2d3h4m5s", sen.Map["uptime"])
+	assert.Equal(t, "7.12.1 (stable)", sen.Map["version"])
+	assert.Equal(t, "21495808", sen.Map["free-memory"])
+	assert.Equal(t, "3", sen.Map["cpu-load"])
+	assert.Equal(t, "RB4011iGS+", sen.Map["board-name"])
+	assert.Equal(t, "37", sen.Map["sfp-temperature"])
+	assert.Equal(t, "1203400", sen.Map["rx-bits-per-second"])
+	assert.Equal(t, "false", sen.Map["write-sect-since-reboot"])
+}
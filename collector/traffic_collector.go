@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type trafficCollector struct {
+	props        []string
+	descriptions map[string]*prometheus.Desc
+	interfaces   *regexp.Regexp
+}
+
+func newTrafficCollector(cfg config.Traffic) routerOSCollector {
+	c := &trafficCollector{}
+
+	if cfg.Interfaces != "" {
+		re, err := regexp.Compile(cfg.Interfaces)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"interfaces": cfg.Interfaces,
+				"error":      err,
+			}).Fatal("invalid traffic interface filter")
+		}
+		c.interfaces = re
+	}
+
+	c.init()
+	return c
+}
+
+func (c *trafficCollector) init() {
+	c.props = []string{
+		"rx-bits-per-second", "tx-bits-per-second",
+		"rx-packets-per-second", "tx-packets-per-second",
+		"fp-rx-bits-per-second", "fp-tx-bits-per-second",
+		"fp-rx-packets-per-second", "fp-tx-packets-per-second",
+	}
+
+	labelNames := []string{"name", "address", "interface"}
+	c.descriptions = make(map[string]*prometheus.Desc)
+	for _, p := range c.props {
+		c.descriptions[p] = descriptionForPropertyName("interface_traffic", p, labelNames)
+	}
+}
+
+func (c *trafficCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *trafficCollector) collect(ctx *collectorContext) error {
+	ifaces, err := c.fetchInterfaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(ifaces) == 0 {
+		return nil
+	}
+
+	// monitor-traffic accepts a comma separated list like =numbers= does
+	reply, err := ctx.client.Run("/interface/monitor-traffic",
+		"=interface="+strings.Join(ifaces, ","),
+		"=once=",
+		"=.proplist=name,"+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching interface traffic metrics")
+		return err
+	}
+
+	for _, re := range reply.Re {
+		c.collectForStat(re, ctx)
+	}
+
+	return nil
+}
+
+// fetchInterfaces returns the running interfaces matching the filter
+func (c *trafficCollector) fetchInterfaces(ctx *collectorContext) ([]string, error) {
+	reply, err := ctx.client.Run("/interface/print", "?running=true", "=.proplist=name")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching interfaces")
+		return nil, err
+	}
+
+	ifaces := make([]string, 0)
+	for _, re := range reply.Re {
+		n := re.Map["name"]
+		if c.interfaces != nil && !c.interfaces.MatchString(n) {
+			continue
+		}
+		ifaces = append(ifaces, n)
+	}
+
+	return ifaces, nil
+}
+
+func (c *trafficCollector) collectForStat(re *proto.Sentence, ctx *collectorContext) {
+	name, ok := re.Map["name"]
+	if !ok {
+		return
+	}
+
+	for _, p := range c.props {
+		value := re.Map[p]
+		if value == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":    ctx.device.Name,
+				"interface": name,
+				"property":  p,
+				"value":     value,
+				"error":     err,
+			}).Error("error parsing interface traffic metric value")
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions[p], prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, name)
+	}
+}
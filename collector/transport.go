@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"fmt"
+
+	"mikrotik-exporter/config"
+
+	routeros "gopkg.in/routeros.v2"
+)
+
+// transport runs requests against a router. Requests are expressed as API
+// sentences, i.e. a command path like "/interface/print" followed by
+// "=name=value" attribute and "?query" words, so collectors work the same
+// regardless of how the router is accessed.
+type transport interface {
+	Run(sentence ...string) (*routeros.Reply, error)
+	Close()
+}
+
+// connect opens a transport to the device as selected in its configuration
+func (c *collector) connect(d *config.Device) (transport, error) {
+	switch d.Transport {
+	case "", config.TransportAPI:
+		// avoid returning a typed nil client
+		cl, err := c.connectAPI(d)
+		if err != nil {
+			return nil, err
+		}
+		return cl, nil
+	case config.TransportREST:
+		return c.connectREST(d)
+	case config.TransportSSH:
+		return c.connectSSH(d)
+	case config.TransportSNMP:
+		return c.connectSNMP(d)
+	}
+
+	return nil, fmt.Errorf("unknown transport %q", d.Transport)
+}
+
+// limitedTransport is implemented by transports which can't serve every
+// collector
+type limitedTransport interface {
+	supports(co routerOSCollector) bool
+}
+
+// skipReason returns why the collector can't run on the device, or an empty
+// string if it can
+func skipReason(cl transport, caps *capabilities, co routerOSCollector) string {
+	if lt, ok := cl.(limitedTransport); ok && !lt.supports(co) {
+		return "not supported by transport"
+	}
+
+	if !caps.supports(co) {
+		return "not supported by device"
+	}
+
+	return ""
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	routeros "gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type vrrpCollector struct {
+	props        []string
+	descriptions map[string]*prometheus.Desc
+	stateDesc    *prometheus.Desc
+	infoDesc     *prometheus.Desc
+}
+
+func newVRRPCollector() routerOSCollector {
+	c := &vrrpCollector{}
+	c.init()
+	return c
+}
+
+func (c *vrrpCollector) init() {
+	c.props = []string{"name", "interface", "master", "backup", "vrid", "priority", "version", "running", "disabled"}
+
+	const prefix = "vrrp"
+	labelNames := []string{"name", "address", "interface"}
+
+	c.stateDesc = description(prefix, "state", "VRRP state (0 = init, 1 = backup, 2 = master)", labelNames)
+	c.descriptions = make(map[string]*prometheus.Desc)
+	c.descriptions["vrid"] = description(prefix, "vrid", "virtual router ID", labelNames)
+	c.descriptions["priority"] = description(prefix, "priority", "VRRP priority", labelNames)
+	c.descriptions["version"] = description(prefix, "version", "VRRP protocol version", labelNames)
+	c.descriptions["running"] = description(prefix, "running", "VRRP interface is running (running = 1)", labelNames)
+
+	c.infoDesc = description(prefix, "info", "VRRP interface details", append(labelNames, "parent_interface", "vrid", "virtual_addresses"))
+}
+
+func (c *vrrpCollector) requires() requirements {
+	return requirements{minVersion: 6}
+}
+
+func (c *vrrpCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.stateDesc
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+	ch <- c.infoDesc
+}
+
+func (c *vrrpCollector) collect(ctx *collectorContext) error {
+	reply, err := ctx.client.Run("/interface/vrrp/print", "?disabled=false", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching vrrp metrics")
+		return err
+	}
+
+	if len(reply.Re) == 0 {
+		return nil
+	}
+
+	addresses, err := c.fetchAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range reply.Re {
+		c.collectForInterface(re, addresses[re.Map["name"]], ctx)
+	}
+
+	return nil
+}
+
+// fetchAddresses returns the IPv4 and IPv6 addresses configured on each
+// interface, the virtual addresses are the ones on the VRRP interfaces
+func (c *vrrpCollector) fetchAddresses(ctx *collectorContext) (map[string][]string, error) {
+	addresses := make(map[string][]string)
+
+	for _, topic := range []string{"ip", "ipv6"} {
+		reply, err := ctx.client.Run("/"+topic+"/address/print", "?disabled=false", "=.proplist=address,interface")
+		if err != nil {
+			// e.g. a disabled ipv6 package
+			if _, ok := err.(*routeros.DeviceError); ok {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"device": ctx.device.Name,
+				"topic":  topic,
+				"error":  err,
+			}).Error("error fetching vrrp addresses")
+			return nil, err
+		}
+
+		for _, re := range reply.Re {
+			iface := re.Map["interface"]
+			addresses[iface] = append(addresses[iface], re.Map["address"])
+		}
+	}
+
+	for _, a := range addresses {
+		sort.Strings(a)
+	}
+
+	return addresses, nil
+}
+
+func (c *vrrpCollector) collectForInterface(re *proto.Sentence, addresses []string, ctx *collectorContext) {
+	name := re.Map["name"]
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1, ctx.device.Name, ctx.device.Address, name, re.Map["interface"], re.Map["vrid"], strings.Join(addresses, ","))
+
+	state := 0.0
+	switch {
+	case re.Map["master"] == "true":
+		state = 2
+	case re.Map["backup"] == "true":
+		state = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.stateDesc, prometheus.GaugeValue, state, ctx.device.Name, ctx.device.Address, name)
+
+	for _, p := range []string{"vrid", "priority", "version", "running"} {
+		value := re.Map[p]
+		if value == "" {
+			continue
+		}
+
+		v, err := c.parseValueForProperty(p, value)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":    ctx.device.Name,
+				"interface": name,
+				"property":  p,
+				"value":     value,
+				"error":     err,
+			}).Error("error parsing vrrp metric value")
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions[p], prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, name)
+	}
+}
+
+func (c *vrrpCollector) parseValueForProperty(property, value string) (float64, error) {
+	if property == "running" {
+		if value == "true" {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
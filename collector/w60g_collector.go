@@ -21,6 +21,10 @@ type w60gInterfaceCollector struct {
 	props                 []string
 }
 
+func (c *w60gInterfaceCollector) requires() requirements {
+	return requirements{interfaceTypes: []string{"w60g"}}
+}
+
 func (c *w60gInterfaceCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.frequencyDesc
 	ch <- c.txMCSDesc
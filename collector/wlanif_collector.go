@@ -30,6 +30,10 @@ func (c *wlanIFCollector) init() {
 	}
 }
 
+func (c *wlanIFCollector) requires() requirements {
+	return requirements{interfaceTypes: []string{"wlan"}}
+}
+
 func (c *wlanIFCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -33,6 +33,10 @@ func (c *wlanSTACollector) init() {
 	}
 }
 
+func (c *wlanSTACollector) requires() requirements {
+	return requirements{interfaceTypes: []string{"wlan"}}
+}
+
 func (c *wlanSTACollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"mikrotik-exporter/collector"
+	"mikrotik-exporter/config"
+	"mikrotik-exporter/discovery"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	cmdCheckConfig    = "check-config"
+	cmdTestConnection = "test-connection"
+	cmdDiscover       = "discover"
+
+	defaultProfileName = "default"
+)
+
+// runCheckConfig strictly parses and validates the config file and returns
+// the process exit code
+func runCheckConfig() int {
+	if *configFile == "" {
+		fmt.Println("missing -config-file")
+		return 2
+	}
+
+	b, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		fmt.Printf("could not read %s: %v\n", *configFile, err)
+		return 2
+	}
+
+	c, err := config.LoadStrict(bytes.NewReader(b))
+	if err != nil {
+		fmt.Printf("could not parse %s: %v\n", *configFile, err)
+		return 1
+	}
+
+	errs := c.Validate()
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", *configFile, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+
+	fmt.Printf("%s: OK (%d devices)\n", *configFile, len(c.Devices))
+	return 0
+}
+
+// runTestConnection logs in to every configured device and reports for
+// every enabled collector whether its API path is reachable. It returns the
+// process exit code.
+func runTestConnection() int {
+	code := 0
+
+	for _, res := range collector.CheckConnections(cfg, collectorOptions()...) {
+		if res.Err != nil {
+			fmt.Printf("%s (%s): FAILED: %v\n", res.Device, res.Address, res.Err)
+			code = 1
+			continue
+		}
+
+		fmt.Printf("%s (%s): login OK\n", res.Device, res.Address)
+		for _, co := range res.Collectors {
+			if co.SkipReason != "" {
+				fmt.Printf("  %-10s %-45s SKIPPED (%s)\n", co.Name, co.Path, co.SkipReason)
+			} else if co.Err != nil {
+				fmt.Printf("  %-10s %-45s FAILED: %v\n", co.Name, co.Path, co.Err)
+				code = 1
+			} else {
+				fmt.Printf("  %-10s %-45s OK\n", co.Name, co.Path)
+			}
+		}
+	}
+
+	return code
+}
+
+// runDiscover scans the given networks for routers and writes a devices
+// list for the config file. It returns the process exit code.
+func runDiscover() int {
+	if *scanCIDRs == "" {
+		fmt.Fprintln(os.Stderr, "missing -cidr")
+		return 2
+	}
+
+	profiles := []config.Profile{}
+	if *configFile != "" {
+		c, err := loadConfigFromFile()
+		if err != nil {
+			log.WithField("error", err).Error("could not load config")
+			return 2
+		}
+		profiles = append(profiles, c.Profiles...)
+	}
+
+	if *user == "" {
+		*user = os.Getenv("MIKROTIK_USER")
+	}
+	if *password == "" {
+		*password = os.Getenv("MIKROTIK_PASSWORD")
+	}
+	if *user != "" {
+		profiles = append(profiles, config.Profile{Name: defaultProfileName, User: *user, Password: *password})
+	}
+
+	if len(profiles) == 0 {
+		fmt.Fprintln(os.Stderr, "no credential profiles, use -user/-password or a config file with profiles")
+		return 2
+	}
+
+	devices, err := discovery.Scan(strings.Split(*scanCIDRs, ","), profiles, discovery.ScanOptions{
+		Concurrency: *scanConcurrency,
+		Timeout:     *timeout,
+		InsecureTLS: *insecure,
+	})
+	if err != nil {
+		log.WithField("error", err).Error("error scanning networks")
+		return 2
+	}
+
+	// the devices refer to the profiles by name, so the profiles used have
+	// to be written as well for the output to be loadable
+	used := []config.Profile{}
+	seen := make(map[string]bool)
+	for _, d := range devices {
+		if seen[d.Profile] {
+			continue
+		}
+		seen[d.Profile] = true
+		for _, p := range profiles {
+			if p.Name == d.Profile {
+				used = append(used, p)
+				break
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(struct {
+		Profiles []config.Profile `yaml:"profiles,omitempty"`
+		Devices  []config.Device  `yaml:"devices"`
+	}{used, devices})
+	if err != nil {
+		log.WithField("error", err).Error("error rendering devices")
+		return 2
+	}
+
+	if *scanOutput == "" {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(*scanOutput, b, 0600)
+	}
+	if err != nil {
+		log.WithField("error", err).Error("error writing devices")
+		return 2
+	}
+
+	log.WithField("devices", len(devices)).Info("discovery finished")
+	return 0
+}
@@ -3,13 +3,15 @@ package config
 import (
 	"io"
 	"io/ioutil"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
 // Config represents the configuration for the exporter
 type Config struct {
-	Devices  []Device `yaml:"devices"`
+	Devices  []Device  `yaml:"devices"`
+	Profiles []Profile `yaml:"profiles,omitempty"`
 	Features struct {
 		BGP       bool `yaml:"bgp,omitempty"`
 		Conntrack bool `yaml:"conntrack,omitempty"`
@@ -30,17 +32,196 @@ type Config struct {
 		Ipsec     bool `yaml:"ipsec,omitempty"`
 		Lte       bool `yaml:"lte,omitempty"`
 		Netwatch  bool `yaml:"netwatch,omitempty"`
+		Neighbor  bool `yaml:"neighbor,omitempty"`
+		Log       bool `yaml:"log,omitempty"`
+		Traffic   bool `yaml:"traffic,omitempty"`
+		Firewall  bool `yaml:"firewall,omitempty"`
+		AddrList  bool `yaml:"address_list,omitempty"`
+		Queue     bool `yaml:"queue,omitempty"`
+		OSPF      bool `yaml:"ospf,omitempty"`
+		BFD       bool `yaml:"bfd,omitempty"`
+		MPLS      bool `yaml:"mpls,omitempty"`
+		VRRP      bool `yaml:"vrrp,omitempty"`
 	} `yaml:"features,omitempty"`
+	Neighbor    Neighbor    `yaml:"neighbor,omitempty"`
+	Topology    Topology    `yaml:"topology,omitempty"`
+	Log         Log         `yaml:"log,omitempty"`
+	Syslog      Syslog      `yaml:"syslog,omitempty"`
+	NetFlow     NetFlow     `yaml:"netflow,omitempty"`
+	Traffic     Traffic     `yaml:"traffic,omitempty"`
+	Firewall    Firewall    `yaml:"firewall,omitempty"`
+	AddressList AddressList `yaml:"address_list,omitempty"`
+	RemoteWrite RemoteWrite `yaml:"remote_write,omitempty"`
+	OTLP        OTLP        `yaml:"otlp,omitempty"`
+	Influx      Influx      `yaml:"influx,omitempty"`
+	Discovery   struct {
+		MNDP MNDP `yaml:"mndp,omitempty"`
+	} `yaml:"discovery,omitempty"`
 }
 
+// Transports a device can be accessed with
+const (
+	TransportAPI  = "api"
+	TransportREST = "rest"
+	TransportSSH  = "ssh"
+	TransportSNMP = "snmp"
+)
+
 // Device represents a target device
 type Device struct {
-	Name     string    `yaml:"name"`
-	Address  string    `yaml:"address,omitempty"`
-	Srv      SrvRecord `yaml:"srv,omitempty"`
-	User     string    `yaml:"user"`
-	Password string    `yaml:"password"`
-	Port     string    `yaml:"port"`
+	Name      string    `yaml:"name"`
+	Address   string    `yaml:"address,omitempty"`
+	Srv       SrvRecord `yaml:"srv,omitempty"`
+	Profile   string    `yaml:"profile,omitempty"`
+	User      string    `yaml:"user,omitempty"`
+	Password  string    `yaml:"password,omitempty"`
+	Port      string    `yaml:"port,omitempty"`
+	TLS       bool      `yaml:"tls,omitempty"`
+	Transport string    `yaml:"transport,omitempty"`
+	SSH       SSH       `yaml:"ssh,omitempty"`
+	SNMP      SNMP      `yaml:"snmp,omitempty"`
+}
+
+// SSH configures the SSH transport of a device. Without a key file the
+// device password is used.
+type SSH struct {
+	KeyFile    string `yaml:"key_file,omitempty"`
+	KnownHosts string `yaml:"known_hosts,omitempty"`
+}
+
+// SNMP configures the SNMP transport of a device
+type SNMP struct {
+	Version      string `yaml:"version,omitempty"`
+	Community    string `yaml:"community,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	AuthProtocol string `yaml:"auth_protocol,omitempty"`
+	AuthPassword string `yaml:"auth_password,omitempty"`
+	PrivProtocol string `yaml:"priv_protocol,omitempty"`
+	PrivPassword string `yaml:"priv_password,omitempty"`
+}
+
+// Profile represents a named set of credentials devices can refer to
+type Profile struct {
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// RemoteWrite configures pushing metrics via the Prometheus remote_write protocol
+type RemoteWrite struct {
+	URL            string            `yaml:"url"`
+	Interval       time.Duration     `yaml:"interval,omitempty"`
+	Timeout        time.Duration     `yaml:"timeout,omitempty"`
+	MaxRetries     int               `yaml:"max_retries,omitempty"`
+	QueueCapacity  int               `yaml:"queue_capacity,omitempty"`
+	BasicAuth      BasicAuth         `yaml:"basic_auth,omitempty"`
+	BearerToken    string            `yaml:"bearer_token,omitempty"`
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+}
+
+// OTLP configures exporting metrics via the OpenTelemetry protocol
+type OTLP struct {
+	Endpoint string            `yaml:"endpoint"`
+	Protocol string            `yaml:"protocol,omitempty"`
+	Interval time.Duration     `yaml:"interval,omitempty"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+	Insecure bool              `yaml:"insecure,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+}
+
+// Influx configures pushing metrics in InfluxDB line protocol
+type Influx struct {
+	URL       string        `yaml:"url"`
+	Interval  time.Duration `yaml:"interval,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+	BasicAuth BasicAuth     `yaml:"basic_auth,omitempty"`
+	Token     string        `yaml:"token,omitempty"`
+}
+
+// Neighbor configures which neighbors the neighbor collector reports
+type Neighbor struct {
+	Interfaces string   `yaml:"interfaces,omitempty"`
+	Protocols  []string `yaml:"protocols,omitempty"`
+	Limit      int      `yaml:"limit,omitempty"`
+}
+
+// Traffic configures which interfaces the traffic collector monitors
+type Traffic struct {
+	Interfaces string `yaml:"interfaces,omitempty"`
+}
+
+// Firewall configures which rules the firewall collector reports
+type Firewall struct {
+	Tables        []string `yaml:"tables,omitempty"`
+	CommentedOnly bool     `yaml:"commented_only,omitempty"`
+	Comments      string   `yaml:"comments,omitempty"`
+}
+
+// AddressList configures address lists counted in addition to the ones
+// referenced by firewall rules
+type AddressList struct {
+	Lists []string `yaml:"lists,omitempty"`
+}
+
+// Log configures the log collector
+type Log struct {
+	Patterns []LogPattern `yaml:"patterns,omitempty"`
+}
+
+// Syslog configures the syslog receiver
+type Syslog struct {
+	Enabled   bool         `yaml:"enabled"`
+	ListenUDP string       `yaml:"listen_udp,omitempty"`
+	ListenTCP string       `yaml:"listen_tcp,omitempty"`
+	Rules     []LogPattern `yaml:"rules,omitempty"`
+}
+
+// NetFlow configures the NetFlow/IPFIX receiver
+type NetFlow struct {
+	Enabled      bool          `yaml:"enabled"`
+	Listen       string        `yaml:"listen,omitempty"`
+	TopPorts     int           `yaml:"top_ports,omitempty"`
+	MaxPorts     int           `yaml:"max_ports,omitempty"`
+	Rerank       time.Duration `yaml:"rerank_interval,omitempty"`
+	SamplingRate int           `yaml:"sampling_rate,omitempty"`
+	SubnetGroups []SubnetGroup `yaml:"subnet_groups,omitempty"`
+}
+
+// SubnetGroup names a set of networks traffic is aggregated by
+type SubnetGroup struct {
+	Name    string   `yaml:"name"`
+	Subnets []string `yaml:"subnets"`
+}
+
+// LogPattern counts the log messages matching the regex
+type LogPattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// Topology configures the topology endpoint, the graph is refreshed every
+// interval
+type Topology struct {
+	Enabled  bool          `yaml:"enabled,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// MNDP configures discovering routers via the MikroTik Neighbor Discovery
+// Protocol
+type MNDP struct {
+	Enabled  bool          `yaml:"enabled"`
+	Listen   string        `yaml:"listen,omitempty"`
+	Profile  string        `yaml:"profile"`
+	Identity string        `yaml:"identity,omitempty"`
+	Subnets  []string      `yaml:"subnets,omitempty"`
+	Expire   time.Duration `yaml:"expire,omitempty"`
+	TLS      bool          `yaml:"tls,omitempty"`
+}
+
+// BasicAuth holds HTTP basic authentication credentials
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
 type SrvRecord struct {
@@ -64,6 +245,50 @@ func Load(r io.Reader) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.applyProfiles()
 
 	return c, nil
 }
+
+// LoadStrict reads YAML from reader like Load, but fails on unknown or
+// duplicate keys
+func LoadStrict(r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	err = yaml.UnmarshalStrict(b, c)
+	if err != nil {
+		return nil, err
+	}
+	c.applyProfiles()
+
+	return c, nil
+}
+
+// FindProfile returns the credential profile with the given name
+func (c *Config) FindProfile(name string) (Profile, bool) {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// applyProfiles fills in the credentials of devices referring to a profile
+func (c *Config) applyProfiles() {
+	for i, d := range c.Devices {
+		if d.Profile == "" || d.User != "" {
+			continue
+		}
+
+		if p, ok := c.FindProfile(d.Profile); ok {
+			c.Devices[i].User = p.User
+			c.Devices[i].Password = p.Password
+		}
+	}
+}
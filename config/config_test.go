@@ -65,3 +65,62 @@ func assertFeature(name string, v bool, t *testing.T) {
 		t.Fatalf("exprected feature %s to be enabled", name)
 	}
 }
+
+func TestShouldFailStrictOnUnknownKey(t *testing.T) {
+	_, err := LoadStrict(bytes.NewReader([]byte("devices: []\nfeaturs:\n  bgp: true\n")))
+	if err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := loadTestFile(t)
+	c, err := LoadStrict(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	if errs := c.Validate(); len(errs) != 0 {
+		t.Fatalf("expected valid config, got %v", errs)
+	}
+
+	c.Devices = append(c.Devices, Device{Name: "test1", User: "foo", Port: "abc"})
+	if errs := c.Validate(); len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+}
+
+func TestValidateNumbersItemsFromOne(t *testing.T) {
+	c := &Config{
+		Log:     Log{Patterns: []LogPattern{{Name: "login", Regex: "login"}, {Regex: "failure"}}},
+		NetFlow: NetFlow{Enabled: true, SubnetGroups: []SubnetGroup{{Subnets: []string{"10.0.0.0/8"}}}},
+	}
+
+	errs := validateLogPatterns("log", c.Log.Patterns)
+	if len(errs) != 1 || errs[0].Error() != "log: pattern #2: missing name" {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	errs = c.validateNetFlow()
+	if len(errs) != 1 || errs[0].Error() != "netflow: subnet group #1: missing name" {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
+
+func TestShouldApplyProfiles(t *testing.T) {
+	c, err := Load(bytes.NewReader([]byte(`
+devices:
+  - name: test1
+    address: 192.168.1.1
+    profile: readonly
+profiles:
+  - name: readonly
+    user: foo
+    password: bar
+`)))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	assertDevice("test1", "192.168.1.1", "foo", "bar", c.Devices[0], t)
+}
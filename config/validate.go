@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// Validate checks the configuration for semantic errors and returns all
+// problems found
+func (c *Config) Validate() []error {
+	errs := []error{}
+	names := make(map[string]bool)
+
+	profiles := make(map[string]bool)
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("profile #%d: missing name", i+1))
+		} else if profiles[p.Name] {
+			errs = append(errs, fmt.Errorf("profile %q: duplicate profile name", p.Name))
+		}
+		profiles[p.Name] = true
+
+		if p.User == "" {
+			errs = append(errs, fmt.Errorf("profile %q: missing user", p.Name))
+		}
+	}
+
+	if len(c.Devices) == 0 {
+		errs = append(errs, fmt.Errorf("no devices configured"))
+	}
+
+	for i, d := range c.Devices {
+		id := fmt.Sprintf("device #%d", i+1)
+		if d.Name != "" {
+			id = fmt.Sprintf("device %q", d.Name)
+		}
+
+		if d.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: missing name", id))
+		} else if names[d.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate device name", id))
+		}
+		names[d.Name] = true
+
+		if d.Address == "" && d.Srv.Record == "" {
+			errs = append(errs, fmt.Errorf("%s: either address or srv record is required", id))
+		}
+		if d.Address != "" && d.Srv.Record != "" {
+			errs = append(errs, fmt.Errorf("%s: address and srv record are mutually exclusive", id))
+		}
+
+		if d.Profile != "" {
+			if _, ok := c.FindProfile(d.Profile); !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown profile %q", id, d.Profile))
+			}
+		} else if d.User == "" && d.Transport != TransportSNMP {
+			errs = append(errs, fmt.Errorf("%s: missing user", id))
+		}
+
+		if d.Port != "" {
+			if err := validatePort(d.Port); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid port %q: %v", id, d.Port, err))
+			}
+		}
+
+		switch d.Transport {
+		case "", TransportAPI, TransportREST, TransportSSH, TransportSNMP:
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown transport %q", id, d.Transport))
+		}
+		if d.Transport == TransportSSH && d.SSH.KeyFile == "" && d.Password == "" {
+			errs = append(errs, fmt.Errorf("%s: ssh transport requires a key file or password", id))
+		}
+		if d.Transport == TransportSNMP {
+			errs = append(errs, validateSNMP(id, d.SNMP)...)
+		}
+
+		if d.Srv.Dns.Address != "" || d.Srv.Dns.Port != 0 {
+			if d.Srv.Dns.Address == "" {
+				errs = append(errs, fmt.Errorf("%s: missing srv dns address", id))
+			}
+			if d.Srv.Dns.Port < 1 || d.Srv.Dns.Port > 65535 {
+				errs = append(errs, fmt.Errorf("%s: invalid srv dns port %d", id, d.Srv.Dns.Port))
+			}
+		}
+	}
+
+	errs = append(errs, validateURL("remote_write url", c.RemoteWrite.URL)...)
+	errs = append(errs, validateURL("influx url", c.Influx.URL)...)
+
+	errs = append(errs, c.validateMNDP()...)
+
+	errs = append(errs, validateLogPatterns("log", c.Log.Patterns)...)
+	errs = append(errs, validateLogPatterns("syslog", c.Syslog.Rules)...)
+	errs = append(errs, c.validateNetFlow()...)
+
+	if c.Neighbor.Interfaces != "" {
+		if _, err := regexp.Compile(c.Neighbor.Interfaces); err != nil {
+			errs = append(errs, fmt.Errorf("neighbor: invalid interfaces regex: %v", err))
+		}
+	}
+
+	if c.Traffic.Interfaces != "" {
+		if _, err := regexp.Compile(c.Traffic.Interfaces); err != nil {
+			errs = append(errs, fmt.Errorf("traffic: invalid interfaces regex: %v", err))
+		}
+	}
+
+	for _, t := range c.Firewall.Tables {
+		if t != "filter" && t != "nat" && t != "mangle" && t != "raw" {
+			errs = append(errs, fmt.Errorf("firewall: invalid table %q, must be filter, nat, mangle or raw", t))
+		}
+	}
+
+	if c.Firewall.Comments != "" {
+		if _, err := regexp.Compile(c.Firewall.Comments); err != nil {
+			errs = append(errs, fmt.Errorf("firewall: invalid comments regex: %v", err))
+		}
+	}
+
+	if c.OTLP.Protocol != "" && c.OTLP.Protocol != "grpc" && c.OTLP.Protocol != "http" {
+		errs = append(errs, fmt.Errorf("otlp: invalid protocol %q, must be grpc or http", c.OTLP.Protocol))
+	}
+
+	return errs
+}
+
+func validatePort(port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+	if p < 1 || p > 65535 {
+		return fmt.Errorf("out of range")
+	}
+
+	return nil
+}
+
+func validateURL(name, u string) []error {
+	if u == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %v", name, err)}
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return []error{fmt.Errorf("%s: unsupported scheme %q", name, parsed.Scheme)}
+	}
+
+	return nil
+}
+
+func (c *Config) validateMNDP() []error {
+	m := c.Discovery.MNDP
+	if !m.Enabled {
+		return nil
+	}
+
+	errs := []error{}
+	if m.Profile == "" {
+		errs = append(errs, fmt.Errorf("mndp: missing profile"))
+	} else if _, ok := c.FindProfile(m.Profile); !ok {
+		errs = append(errs, fmt.Errorf("mndp: unknown profile %q", m.Profile))
+	}
+
+	if m.Identity != "" {
+		if _, err := regexp.Compile(m.Identity); err != nil {
+			errs = append(errs, fmt.Errorf("mndp: invalid identity regex: %v", err))
+		}
+	}
+
+	for _, s := range m.Subnets {
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			errs = append(errs, fmt.Errorf("mndp: invalid subnet %q", s))
+		}
+	}
+
+	return errs
+}
+
+func validateSNMP(id string, s SNMP) []error {
+	errs := []error{}
+
+	switch s.Version {
+	case "", "2c":
+	case "3":
+		if s.Username == "" {
+			errs = append(errs, fmt.Errorf("%s: snmp v3 requires a username", id))
+		}
+		switch s.AuthProtocol {
+		case "", "MD5", "SHA", "SHA256", "SHA512":
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown snmp auth protocol %q", id, s.AuthProtocol))
+		}
+		switch s.PrivProtocol {
+		case "", "DES", "AES", "AES256":
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown snmp priv protocol %q", id, s.PrivProtocol))
+		}
+		if s.PrivProtocol != "" && s.AuthProtocol == "" {
+			errs = append(errs, fmt.Errorf("%s: snmp privacy requires authentication", id))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s: unknown snmp version %q, must be 2c or 3", id, s.Version))
+	}
+
+	return errs
+}
+
+func validateLogPatterns(section string, patterns []LogPattern) []error {
+	errs := []error{}
+	names := make(map[string]bool)
+
+	for i, p := range patterns {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: pattern #%d: missing name", section, i+1))
+		} else if names[p.Name] {
+			errs = append(errs, fmt.Errorf("%s: pattern %q: duplicate name", section, p.Name))
+		}
+		names[p.Name] = true
+
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("%s: pattern %q: invalid regex: %v", section, p.Name, err))
+		}
+	}
+
+	return errs
+}
+
+func (c *Config) validateNetFlow() []error {
+	n := c.NetFlow
+	if !n.Enabled {
+		return nil
+	}
+
+	errs := []error{}
+	if n.TopPorts < 0 || n.MaxPorts < 0 || n.SamplingRate < 0 {
+		errs = append(errs, fmt.Errorf("netflow: top_ports, max_ports and sampling_rate must not be negative"))
+	}
+	if n.Rerank < 0 {
+		errs = append(errs, fmt.Errorf("netflow: rerank_interval must not be negative"))
+	}
+	if n.MaxPorts > 0 && n.TopPorts > n.MaxPorts {
+		errs = append(errs, fmt.Errorf("netflow: top_ports must not exceed max_ports"))
+	}
+
+	names := make(map[string]bool)
+	for i, g := range n.SubnetGroups {
+		if g.Name == "" {
+			errs = append(errs, fmt.Errorf("netflow: subnet group #%d: missing name", i+1))
+		} else if names[g.Name] {
+			errs = append(errs, fmt.Errorf("netflow: subnet group %q: duplicate name", g.Name))
+		}
+		names[g.Name] = true
+
+		for _, s := range g.Subnets {
+			if _, _, err := net.ParseCIDR(s); err != nil {
+				errs = append(errs, fmt.Errorf("netflow: subnet group %q: invalid subnet %q", g.Name, s))
+			}
+		}
+	}
+
+	return errs
+}
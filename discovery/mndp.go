@@ -0,0 +1,312 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMNDPListen is the address MNDP announcements are received on
+	DefaultMNDPListen = ":5678"
+	// DefaultMNDPExpire defines after which silence period a router is removed
+	DefaultMNDPExpire = 5 * time.Minute
+
+	mndpHeaderLength = 4
+	maxPacketSize    = 1500
+)
+
+// MNDP TLV types
+const (
+	mndpMACAddress  = 1
+	mndpIdentity    = 5
+	mndpVersion     = 7
+	mndpPlatform    = 8
+	mndpUptime      = 10
+	mndpSoftwareID  = 11
+	mndpBoard       = 12
+	mndpIPv6Address = 15
+	mndpInterface   = 16
+	mndpIPv4Address = 17
+)
+
+// Announcement is a decoded MNDP packet
+type Announcement struct {
+	MACAddress  net.HardwareAddr
+	Identity    string
+	Version     string
+	Platform    string
+	Uptime      time.Duration
+	SoftwareID  string
+	Board       string
+	Interface   string
+	IPv4Address net.IP
+	IPv6Address net.IP
+}
+
+// DecodeMNDP decodes a MikroTik Neighbor Discovery Protocol packet
+func DecodeMNDP(b []byte) (*Announcement, error) {
+	if len(b) < mndpHeaderLength {
+		return nil, fmt.Errorf("packet too short")
+	}
+
+	a := &Announcement{}
+	b = b[mndpHeaderLength:]
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("truncated TLV header")
+		}
+
+		t := binary.BigEndian.Uint16(b[0:2])
+		l := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+l {
+			return nil, fmt.Errorf("truncated TLV value of type %d", t)
+		}
+		v := b[4 : 4+l]
+		b = b[4+l:]
+
+		switch t {
+		case mndpMACAddress:
+			if l == 6 {
+				a.MACAddress = net.HardwareAddr(append([]byte{}, v...))
+			}
+		case mndpIdentity:
+			a.Identity = string(v)
+		case mndpVersion:
+			a.Version = string(v)
+		case mndpPlatform:
+			a.Platform = string(v)
+		case mndpUptime:
+			if l == 4 {
+				a.Uptime = time.Duration(binary.LittleEndian.Uint32(v)) * time.Second
+			}
+		case mndpSoftwareID:
+			a.SoftwareID = string(v)
+		case mndpBoard:
+			a.Board = string(v)
+		case mndpInterface:
+			a.Interface = string(v)
+		case mndpIPv4Address:
+			if l == net.IPv4len {
+				a.IPv4Address = net.IPv4(v[0], v[1], v[2], v[3])
+			}
+		case mndpIPv6Address:
+			if l == net.IPv6len {
+				a.IPv6Address = net.IP(append([]byte{}, v...))
+			}
+		}
+	}
+
+	return a, nil
+}
+
+type mndpEntry struct {
+	announcement *Announcement
+	device       config.Device
+	lastSeen     time.Time
+	// the device address is taken from the announcements of one interface
+	// only, another one is used once it has been silent for the expiry period
+	interfaceSeen time.Time
+}
+
+// MNDPListener listens for MNDP announcements and keeps the set of routers
+// matching the configured filters
+type MNDPListener struct {
+	cfg      config.MNDP
+	profile  config.Profile
+	identity *regexp.Regexp
+	subnets  []*net.IPNet
+
+	mu      sync.Mutex
+	entries map[string]*mndpEntry
+	now     func() time.Time
+}
+
+// NewMNDPListener creates a listener adding matching routers with the
+// credentials of the given profile
+func NewMNDPListener(cfg config.MNDP, profile config.Profile) (*MNDPListener, error) {
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultMNDPListen
+	}
+	if cfg.Expire == 0 {
+		cfg.Expire = DefaultMNDPExpire
+	}
+
+	l := &MNDPListener{
+		cfg:     cfg,
+		profile: profile,
+		entries: make(map[string]*mndpEntry),
+		now:     time.Now,
+	}
+
+	if cfg.Identity != "" {
+		re, err := regexp.Compile(cfg.Identity)
+		if err != nil {
+			return nil, err
+		}
+		l.identity = re
+	}
+
+	for _, s := range cfg.Subnets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		l.subnets = append(l.subnets, n)
+	}
+
+	return l, nil
+}
+
+// Run receives MNDP packets until an error occurs
+func (l *MNDPListener) Run() error {
+	addr, err := net.ResolveUDPAddr("udp", l.cfg.Listen)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.WithField("listen", l.cfg.Listen).Info("listening for MNDP announcements")
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+
+		a, err := DecodeMNDP(buf[:n])
+		if err != nil {
+			log.WithFields(log.Fields{
+				"source": src.IP.String(),
+				"error":  err,
+			}).Debug("error decoding MNDP packet")
+			continue
+		}
+
+		l.handle(a, src.IP)
+	}
+}
+
+func (l *MNDPListener) handle(a *Announcement, src net.IP) {
+	addr := a.IPv4Address
+	if addr == nil || addr.IsUnspecified() {
+		addr = src
+	}
+
+	if !l.matches(a, addr) {
+		return
+	}
+
+	key := mndpKey(a, addr)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		log.WithFields(log.Fields{
+			"identity": a.Identity,
+			"address":  addr.String(),
+			"mac":      a.MACAddress.String(),
+			"board":    a.Board,
+			"version":  a.Version,
+		}).Info("discovered device via MNDP")
+		e = &mndpEntry{}
+		l.entries[key] = e
+	}
+
+	e.lastSeen = now
+	if ok && a.Interface != e.announcement.Interface && now.Sub(e.interfaceSeen) <= l.cfg.Expire {
+		return
+	}
+
+	e.announcement = a
+	e.interfaceSeen = now
+	e.device = config.Device{
+		Name:     a.Identity,
+		Address:  addr.String(),
+		Profile:  l.profile.Name,
+		User:     l.profile.User,
+		Password: l.profile.Password,
+		TLS:      l.cfg.TLS,
+	}
+	if e.device.Name == "" {
+		e.device.Name = addr.String()
+	}
+}
+
+// mndpKey identifies a router independently of the interface it announces
+// on. The software ID is unique per RouterOS installation, older versions
+// not sending it are told apart by identity which is the device name anyway.
+func mndpKey(a *Announcement, addr net.IP) string {
+	if a.SoftwareID != "" {
+		return a.SoftwareID
+	}
+
+	if a.Identity != "" {
+		return a.Identity
+	}
+
+	return addr.String()
+}
+
+func (l *MNDPListener) matches(a *Announcement, addr net.IP) bool {
+	if l.identity != nil && !l.identity.MatchString(a.Identity) {
+		return false
+	}
+
+	if len(l.subnets) == 0 {
+		return true
+	}
+
+	for _, n := range l.subnets {
+		if n.Contains(addr) || (a.IPv6Address != nil && n.Contains(a.IPv6Address)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Devices returns the routers announced within the expiry period. Routers
+// which have been silent for longer are removed.
+func (l *MNDPListener) Devices() []config.Device {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	devices := []config.Device{}
+	for key, e := range l.entries {
+		if now.Sub(e.lastSeen) > l.cfg.Expire {
+			log.WithFields(log.Fields{
+				"identity": e.announcement.Identity,
+				"address":  e.device.Address,
+			}).Info("MNDP device expired")
+			delete(l.entries, key)
+			continue
+		}
+
+		devices = append(devices, e.device)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+
+	return devices
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tlv(t uint16, v []byte) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b[0:2], t)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(v)))
+	return append(b, v...)
+}
+
+func mndpPacket(identity string, ip net.IP) []byte {
+	uptime := make([]byte, 4)
+	binary.LittleEndian.PutUint32(uptime, 3600)
+
+	b := []byte{0, 0, 0, 1}
+	b = append(b, tlv(mndpMACAddress, []byte{0x4c, 0x5e, 0x0c, 0x01, 0x02, 0x03})...)
+	b = append(b, tlv(mndpIdentity, []byte(identity))...)
+	b = append(b, tlv(mndpVersion, []byte("6.48.1 (stable)"))...)
+	b = append(b, tlv(mndpPlatform, []byte("MikroTik"))...)
+	b = append(b, tlv(mndpUptime, uptime)...)
+	b = append(b, tlv(mndpBoard, []byte("CCR1009-7G-1C-1S+"))...)
+	b = append(b, tlv(mndpInterface, []byte("ether1"))...)
+	b = append(b, tlv(mndpIPv4Address, ip.To4())...)
+
+	return b
+}
+
+func TestDecodeMNDP(t *testing.T) {
+	a, err := DecodeMNDP(mndpPacket("core1", net.ParseIP("10.0.0.1")))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "4c:5e:0c:01:02:03", a.MACAddress.String())
+	assert.Equal(t, "core1", a.Identity)
+	assert.Equal(t, "6.48.1 (stable)", a.Version)
+	assert.Equal(t, "MikroTik", a.Platform)
+	assert.Equal(t, time.Hour, a.Uptime)
+	assert.Equal(t, "CCR1009-7G-1C-1S+", a.Board)
+	assert.Equal(t, "ether1", a.Interface)
+	assert.Equal(t, "10.0.0.1", a.IPv4Address.String())
+
+	_, err = DecodeMNDP([]byte{0, 0, 0, 1, 0, 5, 0, 10, 'a'})
+	assert.Error(t, err)
+}
+
+func TestMNDPListenerFiltersAndExpires(t *testing.T) {
+	l, err := NewMNDPListener(config.MNDP{
+		Identity: "^core",
+		Subnets:  []string{"10.0.0.0/24"},
+		Expire:   time.Minute,
+	}, config.Profile{Name: "readonly", User: "foo", Password: "bar"})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for _, p := range [][]byte{
+		mndpPacket("core1", net.ParseIP("10.0.0.1")),
+		mndpPacket("edge1", net.ParseIP("10.0.0.2")),
+		mndpPacket("core2", net.ParseIP("10.0.1.1")),
+	} {
+		a, err := DecodeMNDP(p)
+		assert.NoError(t, err)
+		l.handle(a, a.IPv4Address)
+	}
+
+	devices := l.Devices()
+	assert.Equal(t, []config.Device{
+		{Name: "core1", Address: "10.0.0.1", Profile: "readonly", User: "foo", Password: "bar"},
+	}, devices)
+
+	now = now.Add(2 * time.Minute)
+	assert.Empty(t, l.Devices())
+}
+
+func TestMNDPListenerMergesInterfaces(t *testing.T) {
+	l, err := NewMNDPListener(config.MNDP{Expire: time.Minute}, config.Profile{})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	announce := func(iface, ip string) {
+		a, err := DecodeMNDP(mndpPacket("core1", net.ParseIP(ip)))
+		assert.NoError(t, err)
+		a.Interface = iface
+		a.SoftwareID = "ABCD-1234"
+		l.handle(a, a.IPv4Address)
+	}
+
+	announce("ether1", "10.0.0.1")
+	announce("ether2", "10.0.1.1")
+	assert.Equal(t, []config.Device{{Name: "core1", Address: "10.0.0.1"}}, l.Devices())
+
+	// the address of another interface is used once the first one is silent
+	now = now.Add(30 * time.Second)
+	announce("ether2", "10.0.1.1")
+	now = now.Add(45 * time.Second)
+	announce("ether2", "10.0.1.1")
+	assert.Equal(t, []config.Device{{Name: "core1", Address: "10.0.1.1"}}, l.Devices())
+}
@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"mikrotik-exporter/collector"
+	"mikrotik-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	apiPort    = "8728"
+	apiPortTLS = "8729"
+
+	// maxScanAddresses limits the size of a single scanned network
+	maxScanAddresses = 1 << 16
+)
+
+// ScanOptions configures a subnet scan
+type ScanOptions struct {
+	Concurrency int
+	Timeout     time.Duration
+	InsecureTLS bool
+}
+
+// Scan probes all addresses of the given networks for the RouterOS API
+// ports, logs in with the first matching credential profile and returns a
+// device entry for every router found
+func Scan(cidrs []string, profiles []config.Profile, opts ScanOptions) ([]config.Device, error) {
+	addrs := []net.IP{}
+	for _, cidr := range cidrs {
+		a, err := hosts(cidr)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, a...)
+	}
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	work := make(chan net.IP)
+	found := make(chan config.Device)
+	wg := sync.WaitGroup{}
+	wg.Add(opts.Concurrency)
+
+	for i := 0; i < opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for ip := range work {
+				if d, ok := probe(ip.String(), profiles, opts); ok {
+					found <- d
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for _, ip := range addrs {
+			work <- ip
+		}
+		close(work)
+		wg.Wait()
+		close(found)
+	}()
+
+	devices := []config.Device{}
+	for d := range found {
+		devices = append(devices, d)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+
+	return uniqueNames(devices), nil
+}
+
+// probe checks the API ports of a single address and tries to log in with
+// each profile
+func probe(addr string, profiles []config.Profile, opts ScanOptions) (config.Device, bool) {
+	for _, port := range []string{apiPort, apiPortTLS} {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, port), opts.Timeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+
+		log.WithFields(log.Fields{
+			"address": addr,
+			"port":    port,
+		}).Debug("found open API port")
+
+		d := config.Device{
+			Address: addr,
+			Port:    port,
+			TLS:     port == apiPortTLS,
+		}
+
+		copts := []collector.Option{collector.WithTimeout(opts.Timeout)}
+		if d.TLS {
+			copts = append(copts, collector.WithTLS(opts.InsecureTLS))
+		}
+
+		for _, p := range profiles {
+			d.User = p.User
+			d.Password = p.Password
+
+			info, err := collector.Identify(d, copts...)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"address": addr,
+					"port":    port,
+					"profile": p.Name,
+					"error":   err,
+				}).Debug("could not log in")
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"address":  addr,
+				"port":     port,
+				"identity": info.Identity,
+				"model":    info.Model,
+			}).Info("discovered device")
+
+			d.Name = info.Identity
+			if d.Name == "" {
+				d.Name = addr
+			}
+			d.Profile = p.Name
+			d.User = ""
+			d.Password = ""
+
+			return d, true
+		}
+
+		log.WithFields(log.Fields{
+			"address": addr,
+			"port":    port,
+		}).Warn("API port open but no credential profile could log in")
+	}
+
+	return config.Device{}, false
+}
+
+// uniqueNames makes sure no two devices share the same name by appending the
+// address to duplicate identities (e.g. the factory default "MikroTik")
+func uniqueNames(devices []config.Device) []config.Device {
+	count := make(map[string]int)
+	for _, d := range devices {
+		count[d.Name]++
+	}
+
+	used := make(map[string]bool)
+	for i, d := range devices {
+		name := d.Name
+		if count[name] > 1 {
+			name = d.Name + "-" + d.Address
+		}
+
+		// the suffixed name may still collide with another identity
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%s-%d", d.Name, d.Address, n)
+		}
+
+		used[name] = true
+		devices[i].Name = name
+	}
+
+	return devices
+}
+
+// hosts returns the usable host addresses of a network
+func hosts(cidr string) ([]net.IP, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		// allow single addresses as well
+		ip = net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid network %q", cidr)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("network %s is too large, at most %d addresses can be scanned", cidr, maxScanAddresses)
+	}
+
+	res := []net.IP{}
+	for cur := ip.Mask(ipnet.Mask); ipnet.Contains(cur); cur = nextIP(cur) {
+		res = append(res, cur)
+	}
+
+	// skip network and broadcast address of IPv4 networks
+	if ip.To4() != nil && len(res) > 2 {
+		res = res[1 : len(res)-1]
+	}
+
+	return res, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHosts(t *testing.T) {
+	var testCases = []struct {
+		cidr     string
+		expected []string
+		hasError bool
+	}{
+		{"192.168.1.0/30", []string{"192.168.1.1", "192.168.1.2"}, false},
+		{"192.168.1.5/32", []string{"192.168.1.5"}, false},
+		{"192.168.1.5", []string{"192.168.1.5"}, false},
+		{"10.0.0.0/8", nil, true},
+		{"foo", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		ips, err := hosts(testCase.cidr)
+		if testCase.hasError {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		res := []string{}
+		for _, ip := range ips {
+			res = append(res, ip.String())
+		}
+		assert.Equal(t, testCase.expected, res)
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	devices := uniqueNames([]config.Device{
+		{Name: "MikroTik", Address: "10.0.0.1"},
+		{Name: "MikroTik", Address: "10.0.0.2"},
+		{Name: "core", Address: "10.0.0.3"},
+	})
+
+	assert.Equal(t, "MikroTik-10.0.0.1", devices[0].Name)
+	assert.Equal(t, "MikroTik-10.0.0.2", devices[1].Name)
+	assert.Equal(t, "core", devices[2].Name)
+
+	devices = uniqueNames([]config.Device{
+		{Name: "MikroTik-10.0.0.1", Address: "10.0.0.9"},
+		{Name: "MikroTik", Address: "10.0.0.1"},
+		{Name: "MikroTik", Address: "10.0.0.2"},
+	})
+
+	names := map[string]bool{}
+	for _, d := range devices {
+		names[d.Name] = true
+	}
+	assert.Len(t, names, 3)
+}
@@ -3,13 +3,18 @@ module mikrotik-exporter
 go 1.13
 
 require (
+	github.com/golang/protobuf v1.5.3
+	github.com/golang/snappy v0.0.4
+	github.com/gosnmp/gosnmp v1.35.0
 	github.com/miekg/dns v1.1.43
 	github.com/prometheus/client_golang v1.4.1
+	github.com/prometheus/client_model v0.3.0
 	github.com/prometheus/common v0.9.1
 	github.com/sirupsen/logrus v1.8.1
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d // indirect
-	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e // indirect
+	github.com/stretchr/testify v1.8.3
+	go.opentelemetry.io/proto/otlp v1.0.0
+	golang.org/x/crypto v0.9.0
+	google.golang.org/grpc v1.56.2
 	gopkg.in/routeros.v2 v2.0.0-20190905230420-1bbf141cdd91
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,136 @@
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"mikrotik-exporter/collector"
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultInterval defines how often metrics are collected and pushed
+	DefaultInterval = 60 * time.Second
+	// DefaultTimeout defines the timeout of a single write request
+	DefaultTimeout = 10 * time.Second
+)
+
+// Handler returns a http.Handler rendering the gathered metrics as InfluxDB
+// line protocol
+func Handler(g prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		mfs, err := g.Gather()
+		if err != nil {
+			// Gather returns whatever it could collect along with the error
+			log.WithField("error", err).Warn("error gathering metrics for influx endpoint")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = writeLineProtocol(w, mfs, collector.MetricPrefixes(), now.UnixNano())
+		if err != nil {
+			log.WithField("error", err).Error("error writing influx line protocol")
+		}
+	})
+}
+
+// Pusher periodically gathers metrics and writes them to an InfluxDB write
+// endpoint
+type Pusher struct {
+	cfg      config.Influx
+	gatherer prometheus.Gatherer
+	client   *http.Client
+}
+
+// NewPusher creates a Pusher for the given configuration
+func NewPusher(cfg config.Influx, g prometheus.Gatherer) *Pusher {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	return &Pusher{
+		cfg:      cfg,
+		gatherer: g,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Run collects and pushes metrics every interval. It never returns.
+func (p *Pusher) Run() {
+	log.WithFields(log.Fields{
+		"url":      p.cfg.URL,
+		"interval": p.cfg.Interval,
+	}).Info("starting influx push")
+
+	p.push()
+
+	t := time.NewTicker(p.cfg.Interval)
+	defer t.Stop()
+	for range t.C {
+		p.push()
+	}
+}
+
+func (p *Pusher) push() {
+	now := time.Now()
+	mfs, err := p.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect along with the error
+		log.WithField("error", err).Warn("error gathering metrics for influx push")
+	}
+
+	b := &bytes.Buffer{}
+	err = writeLineProtocol(b, mfs, collector.MetricPrefixes(), now.UnixNano())
+	if err != nil {
+		log.WithField("error", err).Error("error rendering influx line protocol")
+		return
+	}
+
+	err = p.send(b)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   p.cfg.URL,
+			"error": err,
+		}).Error("error pushing metrics to influx")
+	}
+}
+
+func (p *Pusher) send(body io.Reader) error {
+	req, err := http.NewRequest(http.MethodPost, p.cfg.URL, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "mikrotik-exporter")
+
+	if p.cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(p.cfg.BasicAuth.Username, p.cfg.BasicAuth.Password)
+	} else if p.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+p.cfg.Token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
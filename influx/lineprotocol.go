@@ -0,0 +1,171 @@
+package influx
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	metricsPrefix = "mikrotik_"
+	selfPrefix    = "mikrotik_exporter_"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+type point struct {
+	measurement string
+	tags        []*dto.LabelPair
+	fields      map[string]float64
+	fieldOrder  []string
+	timestamp   int64
+}
+
+// writeLineProtocol renders the router metrics of the gathered families as
+// InfluxDB line protocol. The collector prefix becomes the measurement, the
+// labels become tags and the rest of the metric name becomes the field key.
+// Metrics sharing measurement and tags are merged into a single line.
+func writeLineProtocol(w io.Writer, mfs []*dto.MetricFamily, prefixes []string, ts int64) error {
+	points := make(map[string]*point)
+	keys := make([]string, 0)
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if !strings.HasPrefix(name, metricsPrefix) || strings.HasPrefix(name, selfPrefix) {
+			continue
+		}
+
+		measurement, field := splitMetricName(strings.TrimPrefix(name, metricsPrefix), prefixes)
+
+		for _, m := range mf.GetMetric() {
+			v, ok := value(mf.GetType(), m)
+			if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+
+			t := ts
+			if m.TimestampMs != nil {
+				t = m.GetTimestampMs() * 1e6
+			}
+
+			tags := sortedTags(m.GetLabel())
+			key := pointKey(measurement, tags, t)
+			p, ok := points[key]
+			if !ok {
+				p = &point{
+					measurement: measurement,
+					tags:        tags,
+					fields:      make(map[string]float64),
+					timestamp:   t,
+				}
+				points[key] = p
+				keys = append(keys, key)
+			}
+			if _, ok := p.fields[field]; !ok {
+				p.fieldOrder = append(p.fieldOrder, field)
+			}
+			p.fields[field] = v
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, err := io.WriteString(w, points[k].String()+"\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitMetricName splits e.g. "wlan_station_signal_strength" into the
+// measurement "wlan_station" and the field "signal_strength"
+func splitMetricName(name string, prefixes []string) (string, string) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p+"_") {
+			return p, strings.TrimPrefix(name, p+"_")
+		}
+	}
+
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return name, "value"
+	}
+
+	return name[:i], name[i+1:]
+}
+
+func value(t dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue(), true
+	}
+
+	return 0, false
+}
+
+func sortedTags(pairs []*dto.LabelPair) []*dto.LabelPair {
+	tags := make([]*dto.LabelPair, 0, len(pairs))
+	for _, p := range pairs {
+		// line protocol does not allow empty tag values
+		if p.GetValue() != "" {
+			tags = append(tags, p)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].GetName() < tags[j].GetName()
+	})
+
+	return tags
+}
+
+func pointKey(measurement string, tags []*dto.LabelPair, ts int64) string {
+	return seriesKey(measurement, tags) + " " + strconv.FormatInt(ts, 10)
+}
+
+// seriesKey renders the measurement and tag set part of a line
+func seriesKey(measurement string, tags []*dto.LabelPair) string {
+	b := strings.Builder{}
+	b.WriteString(measurementEscaper.Replace(measurement))
+	for _, t := range tags {
+		b.WriteString(",")
+		b.WriteString(tagEscaper.Replace(t.GetName()))
+		b.WriteString("=")
+		b.WriteString(tagEscaper.Replace(t.GetValue()))
+	}
+
+	return b.String()
+}
+
+func (p *point) String() string {
+	b := strings.Builder{}
+	b.WriteString(seriesKey(p.measurement, p.tags))
+
+	for i, f := range p.fieldOrder {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(tagEscaper.Replace(f))
+		b.WriteString("=")
+		b.WriteString(strconv.FormatFloat(p.fields[f], 'f', -1, 64))
+	}
+
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(p.timestamp, 10))
+
+	return b.String()
+}
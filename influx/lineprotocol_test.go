@@ -0,0 +1,78 @@
+package influx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func labelPairs(kv ...string) []*dto.LabelPair {
+	pairs := []*dto.LabelPair{}
+	for i := 0; i < len(kv); i += 2 {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(kv[i]), Value: proto.String(kv[i+1])})
+	}
+
+	return pairs
+}
+
+func TestSplitMetricName(t *testing.T) {
+	prefixes := []string{"wlan_station", "interface", "wlan"}
+
+	var testCases = []struct {
+		name        string
+		measurement string
+		field       string
+	}{
+		{"wlan_station_signal_strength", "wlan_station", "signal_strength"},
+		{"interface_rx_byte", "interface", "rx_byte"},
+		{"bgp_up", "bgp", "up"},
+		{"up", "up", "value"},
+	}
+
+	for _, testCase := range testCases {
+		m, f := splitMetricName(testCase.name, prefixes)
+		assert.Equal(t, testCase.measurement, m)
+		assert.Equal(t, testCase.field, f)
+	}
+}
+
+func TestWriteLineProtocol(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("mikrotik_interface_rx_byte"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   labelPairs("name", "router 1", "interface", "ether1", "comment", ""),
+					Counter: &dto.Counter{Value: proto.Float64(1024)},
+				},
+			},
+		},
+		{
+			Name: proto.String("mikrotik_interface_tx_byte"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   labelPairs("name", "router 1", "interface", "ether1", "comment", ""),
+					Counter: &dto.Counter{Value: proto.Float64(2048)},
+				},
+			},
+		},
+		{
+			Name: proto.String("mikrotik_exporter_build_info"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{Gauge: &dto.Gauge{Value: proto.Float64(1)}},
+			},
+		},
+	}
+
+	b := &bytes.Buffer{}
+	err := writeLineProtocol(b, mfs, []string{"interface"}, 1000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "interface,interface=ether1,name=router\\ 1 rx_byte=1024,tx_byte=2048 1000\n", b.String())
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/prometheus/common/version"
 
@@ -13,6 +14,13 @@ import (
 
 	"mikrotik-exporter/collector"
 	"mikrotik-exporter/config"
+	"mikrotik-exporter/discovery"
+	"mikrotik-exporter/influx"
+	"mikrotik-exporter/netflow"
+	"mikrotik-exporter/otlp"
+	"mikrotik-exporter/remotewrite"
+	"mikrotik-exporter/syslog"
+	"mikrotik-exporter/topology"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -36,6 +44,16 @@ var (
 	user        = flag.String("user", "", "user for authentication with single device")
 	ver         = flag.Bool("version", false, "find the version of binary")
 
+	once           = flag.Bool("once", false, "collect all devices a single time, write the metrics to -once-output and exit")
+	onceOutput     = flag.String("once-output", "stdout", "output for -once: stdout, textfile or pushgateway")
+	textfilePath   = flag.String("textfile-path", "", "path of the .prom file written with -once-output textfile")
+	pushgatewayURL = flag.String("pushgateway-url", "", "url of the Pushgateway used with -once-output pushgateway")
+	pushgatewayJob = flag.String("pushgateway-job", "mikrotik-exporter", "job name used with -once-output pushgateway")
+
+	scanCIDRs       = flag.String("cidr", "", "comma separated networks to scan with the discover command")
+	scanConcurrency = flag.Int("concurrency", 64, "number of addresses probed in parallel by the discover command")
+	scanOutput      = flag.String("output", "", "file the discover command writes the devices to (default stdout)")
+
 	withBgp       = flag.Bool("with-bgp", false, "retrieves BGP routing infrormation")
 	withConntrack = flag.Bool("with-conntrack", false, "retrieves connection tracking metrics")
 	withRoutes    = flag.Bool("with-routes", false, "retrieves routing table information")
@@ -55,6 +73,16 @@ var (
 	withIpsec     = flag.Bool("with-ipsec", false, "retrieves ipsec metrics")
 	withLte       = flag.Bool("with-lte", false, "retrieves lte metrics")
 	withNetwatch  = flag.Bool("with-netwatch", false, "retrieves netwatch metrics")
+	withNeighbor  = flag.Bool("with-neighbor", false, "retrieves IP neighbor (MNDP/CDP/LLDP) metrics")
+	withLog       = flag.Bool("with-log", false, "counts log entries by topic and severity")
+	withTraffic   = flag.Bool("with-traffic", false, "retrieves real-time interface traffic rates")
+	withFirewall  = flag.Bool("with-firewall", false, "retrieves firewall rule counters")
+	withAddrList  = flag.Bool("with-address-list", false, "retrieves firewall address list sizes")
+	withQueue     = flag.Bool("with-queue", false, "retrieves simple queue and queue tree metrics")
+	withOSPF      = flag.Bool("with-ospf", false, "retrieves OSPF neighbor and LSA metrics")
+	withBFD       = flag.Bool("with-bfd", false, "retrieves BFD session metrics")
+	withMPLS      = flag.Bool("with-mpls", false, "retrieves MPLS LDP and VPLS metrics")
+	withVRRP      = flag.Bool("with-vrrp", false, "retrieves VRRP interface metrics")
 
 	cfg *config.Config
 
@@ -67,7 +95,13 @@ func init() {
 }
 
 func main() {
-	flag.Parse()
+	cmd := ""
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd = args[0]
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
 
 	if *ver {
 		fmt.Printf("\nVersion:   %s\nShort SHA: %s\n\n", appVersion, shortSha)
@@ -76,6 +110,18 @@ func main() {
 
 	configureLog()
 
+	switch cmd {
+	case "":
+	case cmdCheckConfig:
+		os.Exit(runCheckConfig())
+	case cmdDiscover:
+		os.Exit(runDiscover())
+	case cmdTestConnection:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected %s, %s or %s\n", cmd, cmdCheckConfig, cmdTestConnection, cmdDiscover)
+		os.Exit(2)
+	}
+
 	c, err := loadConfig()
 	if err != nil {
 		log.Errorf("Could not load config: %v", err)
@@ -83,6 +129,14 @@ func main() {
 	}
 	cfg = c
 
+	if cmd == cmdTestConnection {
+		os.Exit(runTestConnection())
+	}
+
+	if *once {
+		os.Exit(runOnce())
+	}
+
 	startServer()
 }
 
@@ -144,11 +198,69 @@ func loadConfigFromFlags() (*config.Config, error) {
 }
 
 func startServer() {
-	h, err := createMetricsHandler()
+	opts := []collector.Option{}
+	if cfg.Discovery.MNDP.Enabled {
+		l, err := startMNDPListener()
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, collector.WithDeviceSource(l))
+	}
+
+	registry, err := createRegistry(opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	http.Handle(*metricsPath, h)
+	http.Handle(*metricsPath, createMetricsHandler(registry))
+	http.Handle("/influx", influx.Handler(registry))
+
+	topologyLink := ""
+	if cfg.Topology.Enabled {
+		topologyLink = `<p><a href="/topology">Topology</a> (<a href="/topology?format=dot">DOT</a>)</p>`
+		h := topology.New(cfg.Topology, registry)
+		http.Handle("/topology", h)
+		go h.Run()
+	}
+
+	if cfg.Syslog.Enabled {
+		r, err := syslog.New(cfg.Syslog, cfg.Devices)
+		if err != nil {
+			log.Fatal(err)
+		}
+		registry.MustRegister(r)
+		go func() {
+			log.Fatal(r.Run())
+		}()
+	}
+
+	if cfg.NetFlow.Enabled {
+		r, err := netflow.New(cfg.NetFlow, cfg.Devices)
+		if err != nil {
+			log.Fatal(err)
+		}
+		registry.MustRegister(r)
+		go func() {
+			log.Fatal(r.Run())
+		}()
+	}
+
+	if cfg.RemoteWrite.URL != "" {
+		p := remotewrite.New(cfg.RemoteWrite, registry)
+		registry.MustRegister(p)
+		go p.Run()
+	}
+
+	if cfg.OTLP.Endpoint != "" {
+		e, err := otlp.New(cfg.OTLP, registry, appVersion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go e.Run()
+	}
+
+	if cfg.Influx.URL != "" {
+		go influx.NewPusher(cfg.Influx, registry).Run()
+	}
 
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("ok"))
@@ -160,6 +272,8 @@ func startServer() {
 			<body>
 			<h1>Mikrotik Exporter</h1>
 			<p><a href="` + *metricsPath + `">Metrics</a></p>
+			<p><a href="/influx">Metrics (InfluxDB line protocol)</a></p>
+			` + topologyLink + `
 			</body>
 			</html>`))
 	})
@@ -168,14 +282,30 @@ func startServer() {
 	log.Fatal(http.ListenAndServe(*port, nil))
 }
 
-func createMetricsHandler() (http.Handler, error) {
-	opts := collectorOptions()
-	nc, err := collector.NewCollector(cfg, opts...)
+func startMNDPListener() (*discovery.MNDPListener, error) {
+	p, ok := cfg.FindProfile(cfg.Discovery.MNDP.Profile)
+	if !ok {
+		return nil, fmt.Errorf("unknown MNDP profile %q", cfg.Discovery.MNDP.Profile)
+	}
+
+	l, err := discovery.NewMNDPListener(cfg.Discovery.MNDP, p)
 	if err != nil {
 		return nil, err
 	}
 
-	promhttp.Handler()
+	go func() {
+		log.Fatal(l.Run())
+	}()
+
+	return l, nil
+}
+
+func createRegistry(extra ...collector.Option) (*prometheus.Registry, error) {
+	opts := append(collectorOptions(), extra...)
+	nc, err := collector.NewCollector(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
 
 	registry := prometheus.NewRegistry()
 	err = registry.Register(prometheus.NewGoCollector())
@@ -187,11 +317,15 @@ func createMetricsHandler() (http.Handler, error) {
 		return nil, err
 	}
 
+	return registry, nil
+}
+
+func createMetricsHandler(registry *prometheus.Registry) http.Handler {
 	return promhttp.HandlerFor(registry,
 		promhttp.HandlerOpts{
 			ErrorLog:      log.New(),
 			ErrorHandling: promhttp.ContinueOnError,
-		}), nil
+		})
 }
 
 func collectorOptions() []collector.Option {
@@ -274,6 +408,46 @@ func collectorOptions() []collector.Option {
 		opts = append(opts, collector.WithNetwatch())
 	}
 
+	if *withNeighbor || cfg.Features.Neighbor || cfg.Topology.Enabled {
+		opts = append(opts, collector.WithNeighbor(cfg.Neighbor))
+	}
+
+	if *withLog || cfg.Features.Log {
+		opts = append(opts, collector.WithLog(cfg.Log))
+	}
+
+	if *withTraffic || cfg.Features.Traffic {
+		opts = append(opts, collector.WithTraffic(cfg.Traffic))
+	}
+
+	if *withFirewall || cfg.Features.Firewall {
+		opts = append(opts, collector.WithFirewall(cfg.Firewall))
+	}
+
+	if *withAddrList || cfg.Features.AddrList {
+		opts = append(opts, collector.WithAddressList(cfg.AddressList))
+	}
+
+	if *withQueue || cfg.Features.Queue {
+		opts = append(opts, collector.WithQueue())
+	}
+
+	if *withOSPF || cfg.Features.OSPF {
+		opts = append(opts, collector.WithOSPF())
+	}
+
+	if *withBFD || cfg.Features.BFD {
+		opts = append(opts, collector.WithBFD())
+	}
+
+	if *withMPLS || cfg.Features.MPLS {
+		opts = append(opts, collector.WithMPLS())
+	}
+
+	if *withVRRP || cfg.Features.VRRP {
+		opts = append(opts, collector.WithVRRP())
+	}
+
 	if *timeout != collector.DefaultTimeout {
 		opts = append(opts, collector.WithTimeout(*timeout))
 	}
@@ -282,5 +456,9 @@ func collectorOptions() []collector.Option {
 		opts = append(opts, collector.WithTLS(*insecure))
 	}
 
+	if *insecure {
+		opts = append(opts, collector.WithInsecureTLS())
+	}
+
 	return opts
 }
@@ -0,0 +1,273 @@
+package netflow
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OtherLabel collects the traffic of ports and subnets not exposed separately
+const OtherLabel = "other"
+
+var protocolNames = map[uint8]string{
+	1:   "icmp",
+	2:   "igmp",
+	6:   "tcp",
+	17:  "udp",
+	41:  "ipv6",
+	47:  "gre",
+	50:  "esp",
+	51:  "ah",
+	58:  "ipv6-icmp",
+	89:  "ospf",
+	112: "vrrp",
+	132: "sctp",
+}
+
+func protocolName(p uint8) string {
+	if n, ok := protocolNames[p]; ok {
+		return n
+	}
+	return strconv.Itoa(int(p))
+}
+
+// only ports of these protocols are meaningful
+func hasPorts(p uint8) bool {
+	return p == 6 || p == 17 || p == 132
+}
+
+type counter struct {
+	bytes   float64
+	packets float64
+}
+
+func (c *counter) add(bytes, packets float64) {
+	c.bytes += bytes
+	c.packets += packets
+}
+
+type portKey struct {
+	protocol uint8
+	port     uint16
+}
+
+type subnetGroup struct {
+	name string
+	nets []*net.IPNet
+}
+
+// aggregate holds the traffic counters of a single exporter. At most
+// topPorts ports are counted separately, the traffic of all other ports is
+// counted as other. Free slots are filled on every scrape with the ports
+// with most bytes. Every rerank interval the admitted ports are ranked
+// against the other ports by their bytes within the interval and replaced
+// by busier ones; the counters of an evicted port are folded into other so
+// all counters remain monotonic. At most maxCandidates ports not admitted are
+// ranked per interval.
+type aggregate struct {
+	topPorts      int
+	maxCandidates int
+	rerank        time.Duration
+	ranked        time.Time
+	now           func() time.Time
+
+	protocols map[uint8]*counter
+	ports     map[portKey]*counter
+	// bytes within the rerank interval of admitted and other ports
+	recent     map[portKey]float64
+	candidates map[portKey]float64
+	otherPorts counter
+	subnets    map[string]*counter
+}
+
+func newAggregate(topPorts, maxCandidates int, rerank time.Duration) *aggregate {
+	a := &aggregate{
+		topPorts:      topPorts,
+		maxCandidates: maxCandidates,
+		rerank:        rerank,
+		now:           time.Now,
+		protocols:     make(map[uint8]*counter),
+		ports:         make(map[portKey]*counter),
+		recent:        make(map[portKey]float64),
+		candidates:    make(map[portKey]float64),
+		subnets:       make(map[string]*counter),
+	}
+	a.ranked = a.now()
+
+	return a
+}
+
+// add counts a flow scaled by the sampling interval
+func (a *aggregate) add(f Flow, groups []subnetGroup, sampling uint32) {
+	if f.SamplingInterval > 0 {
+		sampling = f.SamplingInterval
+	}
+	if sampling == 0 {
+		sampling = 1
+	}
+	bytes := float64(f.Bytes) * float64(sampling)
+	packets := float64(f.Packets) * float64(sampling)
+
+	pc, ok := a.protocols[f.Protocol]
+	if !ok {
+		pc = &counter{}
+		a.protocols[f.Protocol] = pc
+	}
+	pc.add(bytes, packets)
+
+	if hasPorts(f.Protocol) {
+		k := portKey{f.Protocol, f.DstPort}
+		if c, ok := a.ports[k]; ok {
+			c.add(bytes, packets)
+			a.recent[k] += bytes
+		} else {
+			a.otherPorts.add(bytes, packets)
+			a.rank(k, bytes)
+		}
+	}
+
+	if len(groups) == 0 {
+		return
+	}
+	g := groupOf(f.DstAddr, groups)
+	sc, ok := a.subnets[g]
+	if !ok {
+		sc = &counter{}
+		a.subnets[g] = sc
+	}
+	sc.add(bytes, packets)
+}
+
+// rank remembers the bytes of a port not admitted within the rerank interval
+func (a *aggregate) rank(k portKey, bytes float64) {
+	if _, ok := a.candidates[k]; ok || len(a.candidates) < a.maxCandidates {
+		a.candidates[k] += bytes
+	}
+}
+
+// admit fills the free slots with the candidates with most bytes. Their
+// counters start at zero, the traffic seen before stays in other.
+func (a *aggregate) admit() {
+	if len(a.ports) >= a.topPorts || len(a.candidates) == 0 {
+		return
+	}
+
+	for _, k := range sortedPorts(a.candidates, nil) {
+		if len(a.ports) >= a.topPorts {
+			break
+		}
+		a.ports[k] = &counter{}
+		a.recent[k] = a.candidates[k]
+		delete(a.candidates, k)
+	}
+}
+
+// reRank replaces admitted ports by candidates with more bytes within the
+// interval and starts the next interval. Evicted ports are counted as other
+// from now on, including the traffic counted for them so far.
+func (a *aggregate) reRank() {
+	bytes := make(map[portKey]float64, len(a.ports)+len(a.candidates))
+	for k := range a.ports {
+		bytes[k] = a.recent[k]
+	}
+	for k, b := range a.candidates {
+		bytes[k] = b
+	}
+
+	top := make(map[portKey]bool, a.topPorts)
+	for _, k := range sortedPorts(bytes, a.ports) {
+		if len(top) >= a.topPorts {
+			break
+		}
+		top[k] = true
+	}
+
+	for k, c := range a.ports {
+		if !top[k] {
+			a.otherPorts.add(c.bytes, c.packets)
+			delete(a.ports, k)
+		}
+	}
+	for k := range top {
+		if _, ok := a.ports[k]; !ok {
+			a.ports[k] = &counter{}
+		}
+	}
+
+	a.recent = make(map[portKey]float64)
+	a.candidates = make(map[portKey]float64)
+	a.ranked = a.now()
+}
+
+// sortedPorts returns the ports by bytes, on equal bytes admitted ports are
+// preferred so they aren't replaced needlessly
+func sortedPorts(bytes map[portKey]float64, admitted map[portKey]*counter) []portKey {
+	keys := make([]portKey, 0, len(bytes))
+	for k := range bytes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		bi, bj := bytes[keys[i]], bytes[keys[j]]
+		if bi != bj {
+			return bi > bj
+		}
+		_, ai := admitted[keys[i]]
+		_, aj := admitted[keys[j]]
+		if ai != aj {
+			return ai
+		}
+		if keys[i].protocol != keys[j].protocol {
+			return keys[i].protocol < keys[j].protocol
+		}
+		return keys[i].port < keys[j].port
+	})
+
+	return keys
+}
+
+func groupOf(ip net.IP, groups []subnetGroup) string {
+	if ip == nil {
+		return OtherLabel
+	}
+	for _, g := range groups {
+		for _, n := range g.nets {
+			if n.Contains(ip) {
+				return g.name
+			}
+		}
+	}
+	return OtherLabel
+}
+
+type portCounter struct {
+	protocol string
+	port     string
+	counter
+}
+
+// portCounters reranks the ports once the interval is over, otherwise
+// admits candidates to free slots, and returns the counters of all admitted
+// ports followed by the traffic of the other ports
+func (a *aggregate) portCounters() []portCounter {
+	if a.now().Sub(a.ranked) >= a.rerank {
+		a.reRank()
+	} else {
+		a.admit()
+	}
+
+	res := make([]portCounter, 0, len(a.ports)+1)
+	for k, c := range a.ports {
+		res = append(res, portCounter{
+			protocol: protocolName(k.protocol),
+			port:     strconv.Itoa(int(k.port)),
+			counter:  *c,
+		})
+	}
+
+	if a.otherPorts.bytes > 0 || a.otherPorts.packets > 0 {
+		res = append(res, portCounter{protocol: OtherLabel, port: OtherLabel, counter: a.otherPorts})
+	}
+
+	return res
+}
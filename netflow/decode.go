@@ -0,0 +1,408 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Flow is a single flow record reduced to the fields used for aggregation
+type Flow struct {
+	Protocol uint8
+	SrcAddr  net.IP
+	DstAddr  net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	Bytes    uint64
+	Packets  uint64
+
+	// SamplingInterval is the 1-in-N packet sampling of the exporter, 0 if unknown
+	SamplingInterval uint32
+}
+
+// information elements shared by NetFlow v9 and IPFIX
+const (
+	fieldInBytes          = 1
+	fieldInPackets        = 2
+	fieldProtocol         = 4
+	fieldSrcPort          = 7
+	fieldSrcAddr4         = 8
+	fieldDstPort          = 11
+	fieldDstAddr4         = 12
+	fieldOutBytes         = 23
+	fieldOutPackets       = 24
+	fieldSrcAddr6         = 27
+	fieldDstAddr6         = 28
+	fieldSamplingInterval = 34
+	fieldSamplingPacket   = 305
+)
+
+const (
+	v5HeaderLen     = 24
+	v5RecordLen     = 48
+	v9HeaderLen     = 20
+	ipfixHeaderLen  = 16
+	variableLength  = 0xffff
+	enterpriseField = 0x8000
+)
+
+var errShortPacket = errors.New("packet too short")
+
+type templateField struct {
+	id     uint16
+	length uint16
+	// enterprise specific fields are skipped
+	enterprise bool
+}
+
+type template struct {
+	fields []templateField
+	// options templates carry exporter settings like the sampling interval
+	options bool
+}
+
+type templateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+type samplingKey struct {
+	exporter string
+	domain   uint32
+}
+
+// Decoder decodes NetFlow v5, v9 and IPFIX packets and keeps the templates
+// and sampling intervals announced by each exporter
+type Decoder struct {
+	mu        sync.Mutex
+	templates map[templateKey]template
+	sampling  map[samplingKey]uint32
+}
+
+// NewDecoder creates a decoder with an empty template cache
+func NewDecoder() *Decoder {
+	return &Decoder{
+		templates: make(map[templateKey]template),
+		sampling:  make(map[samplingKey]uint32),
+	}
+}
+
+// Decode returns the flows of a packet received from exporter. Data records
+// referring to a template not seen yet are dropped.
+func (d *Decoder) Decode(b []byte, exporter string) (version uint16, flows []Flow, err error) {
+	if len(b) < 2 {
+		return 0, nil, errShortPacket
+	}
+
+	version = binary.BigEndian.Uint16(b)
+	switch version {
+	case 5:
+		flows, err = decodeV5(b)
+	case 9:
+		flows, err = d.decodeV9(b, exporter)
+	case 10:
+		flows, err = d.decodeIPFIX(b, exporter)
+	default:
+		err = fmt.Errorf("unsupported version %d", version)
+	}
+
+	return version, flows, err
+}
+
+func decodeV5(b []byte) ([]Flow, error) {
+	if len(b) < v5HeaderLen {
+		return nil, errShortPacket
+	}
+
+	count := int(binary.BigEndian.Uint16(b[2:]))
+	if len(b) < v5HeaderLen+count*v5RecordLen {
+		return nil, errShortPacket
+	}
+
+	// the upper two bits hold the sampling mode
+	sampling := uint32(binary.BigEndian.Uint16(b[22:]) & 0x3fff)
+
+	flows := make([]Flow, 0, count)
+	for i := 0; i < count; i++ {
+		r := b[v5HeaderLen+i*v5RecordLen:]
+		flows = append(flows, Flow{
+			SrcAddr:          net.IP(append([]byte(nil), r[0:4]...)),
+			DstAddr:          net.IP(append([]byte(nil), r[4:8]...)),
+			Packets:          uint64(binary.BigEndian.Uint32(r[16:])),
+			Bytes:            uint64(binary.BigEndian.Uint32(r[20:])),
+			SrcPort:          binary.BigEndian.Uint16(r[32:]),
+			DstPort:          binary.BigEndian.Uint16(r[34:]),
+			Protocol:         r[38],
+			SamplingInterval: sampling,
+		})
+	}
+
+	return flows, nil
+}
+
+func (d *Decoder) decodeV9(b []byte, exporter string) ([]Flow, error) {
+	if len(b) < v9HeaderLen {
+		return nil, errShortPacket
+	}
+
+	domain := binary.BigEndian.Uint32(b[16:])
+	return d.decodeSets(b[v9HeaderLen:], exporter, domain, false)
+}
+
+func (d *Decoder) decodeIPFIX(b []byte, exporter string) ([]Flow, error) {
+	if len(b) < ipfixHeaderLen {
+		return nil, errShortPacket
+	}
+
+	l := int(binary.BigEndian.Uint16(b[2:]))
+	if l < ipfixHeaderLen || l > len(b) {
+		return nil, errShortPacket
+	}
+
+	domain := binary.BigEndian.Uint32(b[12:])
+	return d.decodeSets(b[ipfixHeaderLen:l], exporter, domain, true)
+}
+
+// decodeSets walks the flow sets of a v9 or IPFIX packet
+func (d *Decoder) decodeSets(b []byte, exporter string, domain uint32, ipfix bool) ([]Flow, error) {
+	templateSet, optionsSet := uint16(0), uint16(1)
+	if ipfix {
+		templateSet, optionsSet = 2, 3
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var flows []Flow
+	for len(b) >= 4 {
+		id := binary.BigEndian.Uint16(b)
+		l := int(binary.BigEndian.Uint16(b[2:]))
+		if l < 4 || l > len(b) {
+			return flows, errShortPacket
+		}
+		set := b[4:l]
+		b = b[l:]
+
+		var err error
+		switch {
+		case id == templateSet:
+			err = d.parseTemplates(set, exporter, domain, ipfix)
+		case id == optionsSet && ipfix:
+			err = d.parseIPFIXOptionsTemplates(set, exporter, domain)
+		case id == optionsSet:
+			err = d.parseV9OptionsTemplates(set, exporter, domain)
+		case id >= 256:
+			flows = append(flows, d.parseData(set, templateKey{exporter, domain, id})...)
+		}
+		if err != nil {
+			return flows, err
+		}
+	}
+
+	return flows, nil
+}
+
+func (d *Decoder) parseTemplates(b []byte, exporter string, domain uint32, ipfix bool) error {
+	for len(b) >= 4 {
+		id := binary.BigEndian.Uint16(b)
+		count := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+
+		fields, rest, err := parseFields(b, count, ipfix)
+		if err != nil {
+			return err
+		}
+		b = rest
+
+		d.templates[templateKey{exporter, domain, id}] = template{fields: fields}
+	}
+
+	return nil
+}
+
+func (d *Decoder) parseV9OptionsTemplates(b []byte, exporter string, domain uint32) error {
+	for len(b) >= 6 {
+		id := binary.BigEndian.Uint16(b)
+		scopeLen := int(binary.BigEndian.Uint16(b[2:]))
+		optionLen := int(binary.BigEndian.Uint16(b[4:]))
+		b = b[6:]
+
+		fields, rest, err := parseFields(b, (scopeLen+optionLen)/4, false)
+		if err != nil {
+			return err
+		}
+		b = rest
+
+		d.templates[templateKey{exporter, domain, id}] = template{fields: fields, options: true}
+	}
+
+	return nil
+}
+
+func (d *Decoder) parseIPFIXOptionsTemplates(b []byte, exporter string, domain uint32) error {
+	for len(b) >= 6 {
+		id := binary.BigEndian.Uint16(b)
+		count := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[6:]
+
+		fields, rest, err := parseFields(b, count, true)
+		if err != nil {
+			return err
+		}
+		b = rest
+
+		d.templates[templateKey{exporter, domain, id}] = template{fields: fields, options: true}
+	}
+
+	return nil
+}
+
+func parseFields(b []byte, count int, ipfix bool) ([]templateField, []byte, error) {
+	fields := make([]templateField, 0, count)
+	for i := 0; i < count; i++ {
+		if len(b) < 4 {
+			return nil, nil, errShortPacket
+		}
+
+		f := templateField{
+			id:     binary.BigEndian.Uint16(b),
+			length: binary.BigEndian.Uint16(b[2:]),
+		}
+		b = b[4:]
+
+		if ipfix && f.id&enterpriseField != 0 {
+			if len(b) < 4 {
+				return nil, nil, errShortPacket
+			}
+			f.id &^= enterpriseField
+			f.enterprise = true
+			b = b[4:]
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, b, nil
+}
+
+func (d *Decoder) parseData(b []byte, key templateKey) []Flow {
+	t, ok := d.templates[key]
+	if !ok {
+		return nil
+	}
+
+	sk := samplingKey{key.exporter, key.domain}
+
+	var flows []Flow
+	for len(b) > 0 {
+		f, rest, ok := parseRecord(b, t.fields)
+		if !ok {
+			// the remainder is padding
+			break
+		}
+		b = rest
+
+		if t.options {
+			if f.SamplingInterval > 0 {
+				d.sampling[sk] = f.SamplingInterval
+			}
+			continue
+		}
+
+		if f.SamplingInterval == 0 {
+			f.SamplingInterval = d.sampling[sk]
+		}
+		flows = append(flows, f)
+	}
+
+	return flows
+}
+
+func parseRecord(b []byte, fields []templateField) (Flow, []byte, bool) {
+	var f Flow
+	var outBytes, outPackets uint64
+	read := 0
+
+	for _, tf := range fields {
+		l := int(tf.length)
+		if l == variableLength {
+			if len(b) < 1 {
+				return f, nil, false
+			}
+			l = int(b[0])
+			b = b[1:]
+			if l == 255 {
+				if len(b) < 2 {
+					return f, nil, false
+				}
+				l = int(binary.BigEndian.Uint16(b))
+				b = b[2:]
+			}
+		}
+		if len(b) < l {
+			return f, nil, false
+		}
+		v := b[:l]
+		b = b[l:]
+		read += l
+
+		if tf.enterprise {
+			continue
+		}
+
+		switch tf.id {
+		case fieldInBytes:
+			f.Bytes = uintValue(v)
+		case fieldInPackets:
+			f.Packets = uintValue(v)
+		case fieldOutBytes:
+			outBytes = uintValue(v)
+		case fieldOutPackets:
+			outPackets = uintValue(v)
+		case fieldProtocol:
+			f.Protocol = uint8(uintValue(v))
+		case fieldSrcPort:
+			f.SrcPort = uint16(uintValue(v))
+		case fieldDstPort:
+			f.DstPort = uint16(uintValue(v))
+		case fieldSrcAddr4, fieldSrcAddr6:
+			f.SrcAddr = ipValue(v)
+		case fieldDstAddr4, fieldDstAddr6:
+			f.DstAddr = ipValue(v)
+		case fieldSamplingInterval, fieldSamplingPacket:
+			f.SamplingInterval = uint32(uintValue(v))
+		}
+	}
+
+	// a record without any data is padding
+	if read == 0 {
+		return f, nil, false
+	}
+
+	if f.Bytes == 0 {
+		f.Bytes = outBytes
+	}
+	if f.Packets == 0 {
+		f.Packets = outPackets
+	}
+
+	return f, b, true
+}
+
+func uintValue(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ipValue(b []byte) net.IP {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil
+	}
+	return net.IP(append([]byte(nil), b...))
+}
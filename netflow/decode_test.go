@@ -0,0 +1,127 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func set(id uint16, body []byte) []byte {
+	return join(u16(id), u16(uint16(len(body)+4)), body)
+}
+
+func TestDecodeV5(t *testing.T) {
+	header := join(u16(5), u16(1), u32(0), u32(0), u32(0), u32(0), []byte{0, 0}, u16(0x4000|100))
+	record := join(
+		net.ParseIP("10.0.0.1").To4(), net.ParseIP("192.0.2.1").To4(), u32(0),
+		u16(1), u16(2), u32(10), u32(1500), u32(0), u32(0),
+		u16(51000), u16(443), []byte{0, 0, 6, 0}, u16(0), u16(0), []byte{0, 0}, u16(0),
+	)
+
+	version, flows, err := NewDecoder().Decode(join(header, record), "10.0.0.254")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(5), version)
+	if assert.Len(t, flows, 1) {
+		f := flows[0]
+		assert.Equal(t, uint8(6), f.Protocol)
+		assert.Equal(t, uint16(443), f.DstPort)
+		assert.Equal(t, uint64(1500), f.Bytes)
+		assert.Equal(t, uint64(10), f.Packets)
+		assert.Equal(t, uint32(100), f.SamplingInterval)
+		assert.Equal(t, "192.0.2.1", f.DstAddr.String())
+	}
+
+	_, _, err = NewDecoder().Decode(header, "10.0.0.254")
+	assert.Error(t, err)
+}
+
+func TestDecodeV9(t *testing.T) {
+	d := NewDecoder()
+	header := join(u16(9), u16(2), u32(0), u32(0), u32(1), u32(7))
+
+	data := set(256, join(
+		net.ParseIP("192.0.2.1").To4(), u16(53), []byte{17}, u32(300), u32(3),
+		[]byte{0, 0, 0}, // padding
+	))
+
+	// data before its template is dropped
+	_, flows, err := d.Decode(join(header, data), "10.0.0.254")
+	assert.NoError(t, err)
+	assert.Empty(t, flows)
+
+	tmpl := set(0, join(u16(256), u16(5),
+		u16(fieldDstAddr4), u16(4),
+		u16(fieldDstPort), u16(2),
+		u16(fieldProtocol), u16(1),
+		u16(fieldInBytes), u16(4),
+		u16(fieldInPackets), u16(4),
+	))
+	_, flows, err = d.Decode(join(header, tmpl, data), "10.0.0.254")
+	assert.NoError(t, err)
+	if assert.Len(t, flows, 1) {
+		assert.Equal(t, uint8(17), flows[0].Protocol)
+		assert.Equal(t, uint16(53), flows[0].DstPort)
+		assert.Equal(t, uint64(300), flows[0].Bytes)
+		assert.Equal(t, uint64(3), flows[0].Packets)
+	}
+
+	// templates are kept per exporter
+	_, flows, _ = d.Decode(join(header, data), "10.0.0.253")
+	assert.Empty(t, flows)
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	d := NewDecoder()
+
+	tmpl := set(2, join(u16(300), u16(4),
+		u16(fieldDstAddr6), u16(16),
+		u16(fieldProtocol), u16(1),
+		u16(fieldOutBytes), u16(8),
+		u16(enterpriseField|1), u16(0xffff), u32(14988),
+	))
+	options := set(3, join(u16(301), u16(2), u16(1),
+		u16(149), u16(4), // observationDomainId scope
+		u16(fieldSamplingPacket), u16(4),
+	))
+	optionsData := set(301, join(u32(7), u32(1000)))
+	data := set(300, join(
+		net.ParseIP("2001:db8::1").To16(), []byte{58}, []byte{0, 0, 0, 0, 0, 0, 0, 200},
+		[]byte{2, 0xaa, 0xbb},
+	))
+
+	body := join(tmpl, options, optionsData, data)
+	header := join(u16(10), u16(uint16(ipfixHeaderLen+len(body))), u32(0), u32(0), u32(7))
+
+	version, flows, err := d.Decode(join(header, body), "10.0.0.254")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(10), version)
+	if assert.Len(t, flows, 1) {
+		f := flows[0]
+		assert.Equal(t, "2001:db8::1", f.DstAddr.String())
+		assert.Equal(t, uint8(58), f.Protocol)
+		assert.Equal(t, uint64(200), f.Bytes)
+		assert.Equal(t, uint32(1000), f.SamplingInterval)
+	}
+}
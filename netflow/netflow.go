@@ -0,0 +1,244 @@
+package netflow
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultListen is the address flows are received on if none is configured
+	DefaultListen = ":2055"
+	// DefaultTopPorts is the number of destination ports exposed per device
+	DefaultTopPorts = 20
+	// DefaultMaxPorts is the number of destination ports per device ranked
+	// against the exposed ports
+	DefaultMaxPorts = 1000
+	// DefaultRerank is the interval the exposed ports are ranked by
+	DefaultRerank = 10 * time.Minute
+)
+
+const maxPacketSize = 64 * 1024
+
+var (
+	protocolBytesDesc   = desc("protocol_bytes_total", "number of bytes by IP protocol", "protocol")
+	protocolPacketsDesc = desc("protocol_packets_total", "number of packets by IP protocol", "protocol")
+	portBytesDesc       = desc("port_bytes_total", "number of bytes of the top destination ports", "protocol", "port")
+	portPacketsDesc     = desc("port_packets_total", "number of packets of the top destination ports", "protocol", "port")
+	subnetBytesDesc     = desc("subnet_bytes_total", "number of bytes by destination subnet group", "group")
+	subnetPacketsDesc   = desc("subnet_packets_total", "number of packets by destination subnet group", "group")
+)
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("mikrotik", "netflow", name),
+		help,
+		append([]string{"name", "address"}, labels...),
+		nil,
+	)
+}
+
+// Receiver receives NetFlow v5, v9 and IPFIX packets of the configured
+// devices and aggregates the flows per device
+type Receiver struct {
+	cfg      config.NetFlow
+	groups   []subnetGroup
+	byIP     map[string]config.Device
+	sampling uint32
+	decoder  *Decoder
+
+	mu         sync.Mutex
+	aggregates map[string]*aggregate
+
+	packets      *prometheus.CounterVec
+	decodeErrors prometheus.Counter
+	unknown      prometheus.Counter
+}
+
+// New creates a receiver mapping exporters to the given devices
+func New(cfg config.NetFlow, devices []config.Device) (*Receiver, error) {
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultListen
+	}
+	if cfg.MaxPorts == 0 {
+		cfg.MaxPorts = DefaultMaxPorts
+	}
+	if cfg.TopPorts == 0 {
+		cfg.TopPorts = DefaultTopPorts
+	}
+	if cfg.Rerank == 0 {
+		cfg.Rerank = DefaultRerank
+	}
+	if cfg.TopPorts > cfg.MaxPorts {
+		cfg.TopPorts = cfg.MaxPorts
+	}
+
+	r := &Receiver{
+		cfg:        cfg,
+		byIP:       make(map[string]config.Device),
+		sampling:   uint32(cfg.SamplingRate),
+		decoder:    NewDecoder(),
+		aggregates: make(map[string]*aggregate),
+		packets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mikrotik_exporter",
+			Subsystem: "netflow",
+			Name:      "packets_total",
+			Help:      "number of flow export packets received by version",
+		}, []string{"version"}),
+		decodeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "mikrotik_exporter",
+			Subsystem: "netflow",
+			Name:      "decode_errors_total",
+			Help:      "number of flow export packets which could not be decoded",
+		}),
+		unknown: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "mikrotik_exporter",
+			Subsystem: "netflow",
+			Name:      "unknown_exporter_packets_total",
+			Help:      "number of flow export packets which could not be mapped to a device",
+		}),
+	}
+
+	for _, g := range cfg.SubnetGroups {
+		sg := subnetGroup{name: g.Name}
+		for _, s := range g.Subnets {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			sg.nets = append(sg.nets, n)
+		}
+		r.groups = append(r.groups, sg)
+	}
+
+	for _, d := range devices {
+		if d.Address == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(d.Address); ip != nil {
+			r.byIP[ip.String()] = d
+			continue
+		}
+
+		addrs, err := net.LookupHost(d.Address)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":  d.Name,
+				"address": d.Address,
+				"error":   err,
+			}).Warn("could not resolve device address for netflow")
+			continue
+		}
+		for _, a := range addrs {
+			r.byIP[net.ParseIP(a).String()] = d
+		}
+	}
+
+	return r, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *Receiver) Describe(ch chan<- *prometheus.Desc) {
+	ch <- protocolBytesDesc
+	ch <- protocolPacketsDesc
+	ch <- portBytesDesc
+	ch <- portPacketsDesc
+	ch <- subnetBytesDesc
+	ch <- subnetPacketsDesc
+	r.packets.Describe(ch)
+	r.decodeErrors.Describe(ch)
+	r.unknown.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (r *Receiver) Collect(ch chan<- prometheus.Metric) {
+	r.packets.Collect(ch)
+	r.decodeErrors.Collect(ch)
+	r.unknown.Collect(ch)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ip, a := range r.aggregates {
+		d := r.byIP[ip]
+
+		for p, c := range a.protocols {
+			name := protocolName(p)
+			ch <- prometheus.MustNewConstMetric(protocolBytesDesc, prometheus.CounterValue, c.bytes, d.Name, d.Address, name)
+			ch <- prometheus.MustNewConstMetric(protocolPacketsDesc, prometheus.CounterValue, c.packets, d.Name, d.Address, name)
+		}
+
+		for _, p := range a.portCounters() {
+			ch <- prometheus.MustNewConstMetric(portBytesDesc, prometheus.CounterValue, p.bytes, d.Name, d.Address, p.protocol, p.port)
+			ch <- prometheus.MustNewConstMetric(portPacketsDesc, prometheus.CounterValue, p.packets, d.Name, d.Address, p.protocol, p.port)
+		}
+
+		for g, c := range a.subnets {
+			ch <- prometheus.MustNewConstMetric(subnetBytesDesc, prometheus.CounterValue, c.bytes, d.Name, d.Address, g)
+			ch <- prometheus.MustNewConstMetric(subnetPacketsDesc, prometheus.CounterValue, c.packets, d.Name, d.Address, g)
+		}
+	}
+}
+
+// Run receives flow export packets until an error occurs
+func (r *Receiver) Run() error {
+	conn, err := net.ListenPacket("udp", r.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.WithField("listen", r.cfg.Listen).Info("listening for NetFlow/IPFIX packets")
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		src := ""
+		if a, ok := addr.(*net.UDPAddr); ok {
+			src = a.IP.String()
+		}
+		r.handle(buf[:n], src)
+	}
+}
+
+func (r *Receiver) handle(b []byte, src string) {
+	if _, ok := r.byIP[src]; !ok {
+		r.unknown.Inc()
+		return
+	}
+
+	version, flows, err := r.decoder.Decode(b, src)
+	if err != nil {
+		r.decodeErrors.Inc()
+		log.WithFields(log.Fields{
+			"source": src,
+			"error":  err,
+		}).Debug("error decoding flow export packet")
+	}
+	if version == 5 || version == 9 || version == 10 {
+		r.packets.WithLabelValues(strconv.Itoa(int(version))).Inc()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.aggregates[src]
+	if !ok {
+		a = newAggregate(r.cfg.TopPorts, r.cfg.MaxPorts, r.cfg.Rerank)
+		r.aggregates[src] = a
+	}
+	for _, f := range flows {
+		a.add(f, r.groups, r.sampling)
+	}
+}
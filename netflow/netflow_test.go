@@ -0,0 +1,112 @@
+package netflow
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortCounters(t *testing.T) {
+	a := newAggregate(2, 3, time.Hour)
+	now := a.ranked
+	a.now = func() time.Time { return now }
+	for i, port := range []uint16{443, 80, 53, 22, 443} {
+		a.add(Flow{Protocol: 6, DstPort: port, Bytes: uint64(100 * (i + 1)), Packets: 1}, nil, 0)
+	}
+	a.add(Flow{Protocol: 1, Bytes: 50, Packets: 1}, nil, 0)
+
+	// 22 exceeds the ranked candidates
+	assert.Len(t, a.candidates, 3)
+	assert.Equal(t, counter{1500, 5}, *a.protocols[6])
+	assert.Equal(t, counter{50, 1}, *a.protocols[1])
+
+	// the ports with most bytes are admitted, the traffic so far stays in other
+	assert.ElementsMatch(t, []portCounter{
+		{"tcp", "443", counter{}},
+		{"tcp", "53", counter{}},
+		{OtherLabel, OtherLabel, counter{1500, 5}},
+	}, a.portCounters())
+
+	// admitted ports stay within the rerank interval even with less traffic
+	a.add(Flow{Protocol: 6, DstPort: 80, Bytes: 1000, Packets: 1}, nil, 0)
+	a.add(Flow{Protocol: 6, DstPort: 53, Bytes: 10, Packets: 1}, nil, 0)
+	assert.ElementsMatch(t, []portCounter{
+		{"tcp", "443", counter{}},
+		{"tcp", "53", counter{10, 1}},
+		{OtherLabel, OtherLabel, counter{2500, 6}},
+	}, a.portCounters())
+	assert.Equal(t, map[portKey]float64{{6, 80}: 1200}, a.candidates)
+
+	// a busier port replaces the least busy one, whose traffic moves to other
+	now = now.Add(time.Hour)
+	assert.ElementsMatch(t, []portCounter{
+		{"tcp", "443", counter{}},
+		{"tcp", "80", counter{}},
+		{OtherLabel, OtherLabel, counter{2510, 7}},
+	}, a.portCounters())
+	assert.Empty(t, a.candidates)
+	assert.Empty(t, a.recent)
+}
+
+func TestSampling(t *testing.T) {
+	a := newAggregate(10, 10, time.Hour)
+	a.portCounters()
+	a.add(Flow{Protocol: 17, DstPort: 53, Bytes: 10, Packets: 1}, nil, 0)
+	a.portCounters()
+	a.add(Flow{Protocol: 17, DstPort: 53, Bytes: 10, Packets: 1}, nil, 0)
+	a.add(Flow{Protocol: 17, DstPort: 53, Bytes: 10, Packets: 1}, nil, 100)
+	a.add(Flow{Protocol: 17, DstPort: 53, Bytes: 10, Packets: 1, SamplingInterval: 10}, nil, 100)
+
+	assert.Equal(t, counter{1110, 111}, *a.ports[portKey{17, 53}])
+	assert.Equal(t, counter{10, 1}, a.otherPorts)
+}
+
+func TestReceiver(t *testing.T) {
+	r, err := New(config.NetFlow{
+		Enabled: true,
+		SubnetGroups: []config.SubnetGroup{
+			{Name: "servers", Subnets: []string{"192.0.2.0/24", "2001:db8::/32"}},
+		},
+	}, []config.Device{{Name: "edge1", Address: "10.0.0.1"}})
+	assert.NoError(t, err)
+
+	header := join(u16(5), u16(2), u32(0), u32(0), u32(0), u32(0), []byte{0, 0}, u16(0))
+	record := func(dst string, proto byte) []byte {
+		return join(
+			net.ParseIP("10.0.0.5").To4(), net.ParseIP(dst).To4(), u32(0),
+			u16(1), u16(2), u32(2), u32(1000), u32(0), u32(0),
+			u16(51000), u16(443), []byte{0, 0, proto, 0}, u16(0), u16(0), []byte{0, 0}, u16(0),
+		)
+	}
+	packet := join(header, record("192.0.2.10", 6), record("198.51.100.1", 17))
+
+	r.handle(packet, "10.0.0.1")
+	// admits the ports seen so far
+	testutil.CollectAndCount(r)
+	r.handle(packet, "10.0.0.1")
+	r.handle(packet, "10.0.0.99")
+	r.handle([]byte{0, 5}, "10.0.0.1")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.unknown))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.decodeErrors))
+
+	expected := `
+# HELP mikrotik_netflow_subnet_bytes_total number of bytes by destination subnet group
+# TYPE mikrotik_netflow_subnet_bytes_total counter
+mikrotik_netflow_subnet_bytes_total{address="10.0.0.1",group="other",name="edge1"} 2000
+mikrotik_netflow_subnet_bytes_total{address="10.0.0.1",group="servers",name="edge1"} 2000
+# HELP mikrotik_netflow_port_packets_total number of packets of the top destination ports
+# TYPE mikrotik_netflow_port_packets_total counter
+mikrotik_netflow_port_packets_total{address="10.0.0.1",name="edge1",port="443",protocol="tcp"} 2
+mikrotik_netflow_port_packets_total{address="10.0.0.1",name="edge1",port="443",protocol="udp"} 2
+mikrotik_netflow_port_packets_total{address="10.0.0.1",name="edge1",port="other",protocol="other"} 4
+`
+	assert.NoError(t, testutil.CollectAndCompare(r, strings.NewReader(expected),
+		"mikrotik_netflow_subnet_bytes_total", "mikrotik_netflow_port_packets_total"))
+}
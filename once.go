@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"mikrotik-exporter/collector"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	onceOutputStdout      = "stdout"
+	onceOutputTextfile    = "textfile"
+	onceOutputPushgateway = "pushgateway"
+
+	scrapeSuccessMetric = "mikrotik_scrape_collector_success"
+)
+
+// runOnce collects all devices a single time, writes the result to the
+// configured output and returns the process exit code: 0 if all devices were
+// collected successfully, 1 if any device failed and 2 if the output failed.
+func runOnce() int {
+	nc, err := collector.NewCollector(cfg, collectorOptions()...)
+	if err != nil {
+		log.WithField("error", err).Error("error creating collector")
+		return 2
+	}
+
+	registry := prometheus.NewRegistry()
+	err = registry.Register(nc)
+	if err != nil {
+		log.WithField("error", err).Error("error registering collector")
+		return 2
+	}
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		log.WithField("error", err).Warn("error gathering metrics")
+	}
+
+	results := deviceResults(mfs)
+
+	switch *onceOutput {
+	case onceOutputStdout:
+		err = writeFamilies(os.Stdout, mfs)
+	case onceOutputTextfile:
+		err = writeTextfile(*textfilePath, mfs)
+	case onceOutputPushgateway:
+		err = pushToGateway(mfs, results)
+	default:
+		err = fmt.Errorf("unknown output %q", *onceOutput)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"output": *onceOutput,
+			"error":  err,
+		}).Error("error writing metrics")
+		return 2
+	}
+
+	code := 0
+	for _, dev := range sortedKeys(results) {
+		if results[dev] {
+			log.WithField("device", dev).Info("device collected successfully")
+		} else {
+			log.WithField("device", dev).Error("device collection failed")
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// deviceResults reads the per device scrape success from the gathered metrics
+func deviceResults(mfs []*dto.MetricFamily) map[string]bool {
+	results := make(map[string]bool)
+	for _, mf := range mfs {
+		if mf.GetName() != scrapeSuccessMetric {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			results[deviceForMetric(m)] = m.GetGauge().GetValue() == 1
+		}
+	}
+
+	return results
+}
+
+func deviceForMetric(m *dto.Metric) string {
+	labels := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	for _, n := range collector.DeviceLabelNames {
+		if v := labels[n]; v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// familiesForDevice returns only the metrics belonging to the given device
+func familiesForDevice(mfs []*dto.MetricFamily, device string) []*dto.MetricFamily {
+	res := []*dto.MetricFamily{}
+	for _, mf := range mfs {
+		metrics := []*dto.Metric{}
+		for _, m := range mf.GetMetric() {
+			if deviceForMetric(m) == device {
+				metrics = append(metrics, m)
+			}
+		}
+
+		if len(metrics) > 0 {
+			res = append(res, &dto.MetricFamily{
+				Name:   mf.Name,
+				Help:   mf.Help,
+				Type:   mf.Type,
+				Metric: metrics,
+			})
+		}
+	}
+
+	return res
+}
+
+func writeFamilies(w io.Writer, mfs []*dto.MetricFamily) error {
+	for _, mf := range mfs {
+		_, err := expfmt.MetricFamilyToText(w, mf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTextfile writes the metrics for the node_exporter textfile collector.
+// The file is written to a temporary file first and renamed afterwards so the
+// node_exporter never reads a partially written file.
+func writeTextfile(path string, mfs []*dto.MetricFamily) error {
+	if path == "" {
+		return fmt.Errorf("missing textfile path")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = writeFamilies(tmp, mfs)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// pushToGateway pushes the metrics of every device to the Pushgateway,
+// grouped by device name
+func pushToGateway(mfs []*dto.MetricFamily, results map[string]bool) error {
+	if *pushgatewayURL == "" {
+		return fmt.Errorf("missing pushgateway url")
+	}
+
+	var lastErr error
+	for _, dev := range sortedKeys(results) {
+		devMfs := familiesForDevice(mfs, dev)
+		g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return devMfs, nil
+		})
+
+		err := push.New(*pushgatewayURL, *pushgatewayJob).
+			Grouping("instance", dev).
+			Gatherer(g).
+			Push()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device": dev,
+				"error":  err,
+			}).Error("error pushing metrics to pushgateway")
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,323 @@
+package otlp
+
+import (
+	"sort"
+	"strings"
+
+	"mikrotik-exporter/collector"
+
+	dto "github.com/prometheus/client_model/go"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	serviceName   = "mikrotik-exporter"
+	metricsPrefix = "mikrotik_"
+	systemPrefix  = "mikrotik_system_"
+	selfPrefix    = "mikrotik_exporter_"
+)
+
+type resourceKey struct {
+	device  string
+	address string
+}
+
+type identity struct {
+	address string
+	board   string
+	version string
+}
+
+// converter turns gathered metric families into OTLP resource metrics,
+// grouping datapoints by router
+type converter struct {
+	startTime uint64
+	now       uint64
+	scope     *commonpb.InstrumentationScope
+
+	identities map[string]*identity
+	resources  map[resourceKey]map[string]*metricspb.Metric
+	order      []resourceKey
+}
+
+func newConverter(startTime, now uint64, version string) *converter {
+	return &converter{
+		startTime:  startTime,
+		now:        now,
+		scope:      &commonpb.InstrumentationScope{Name: serviceName, Version: version},
+		identities: make(map[string]*identity),
+		resources:  make(map[resourceKey]map[string]*metricspb.Metric),
+	}
+}
+
+func (c *converter) convert(mfs []*dto.MetricFamily) []*metricspb.ResourceMetrics {
+	c.collectIdentities(mfs)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			key, attrs := c.splitLabels(mf.GetName(), m.GetLabel())
+			c.addDataPoint(key, mf, m, attrs)
+		}
+	}
+
+	return c.resourceMetrics()
+}
+
+// collectIdentities looks up address, board and version of every router so
+// they can be attached as resource attributes instead of datapoint labels
+func (c *converter) collectIdentities(mfs []*dto.MetricFamily) {
+	for _, mf := range mfs {
+		if !isDeviceMetric(mf.GetName()) {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			labels := labelMap(m.GetLabel())
+			_, device := deviceFromLabels(labels)
+			if device == "" {
+				continue
+			}
+
+			id := c.identity(device)
+			if v := labels["address"]; v != "" {
+				id.address = v
+			}
+			if isResourceMetric(mf.GetName(), labels) {
+				if v := labels["boardname"]; v != "" {
+					id.board = v
+				}
+				if v := labels["version"]; v != "" {
+					id.version = v
+				}
+			}
+		}
+	}
+}
+
+func (c *converter) identity(device string) *identity {
+	id, ok := c.identities[device]
+	if !ok {
+		id = &identity{}
+		c.identities[device] = id
+	}
+
+	return id
+}
+
+func (c *converter) splitLabels(name string, pairs []*dto.LabelPair) (resourceKey, []*commonpb.KeyValue) {
+	if !isDeviceMetric(name) {
+		return resourceKey{}, attributes(pairs, nil)
+	}
+
+	labels := labelMap(pairs)
+	deviceLabel, device := deviceFromLabels(labels)
+	if device == "" {
+		return resourceKey{}, attributes(pairs, nil)
+	}
+
+	skip := map[string]bool{"address": true, deviceLabel: true}
+	if isResourceMetric(name, labels) {
+		skip["boardname"] = true
+		skip["version"] = true
+	}
+
+	return resourceKey{device: device, address: c.identity(device).address}, attributes(pairs, skip)
+}
+
+func (c *converter) metricFor(key resourceKey, mf *dto.MetricFamily) *metricspb.Metric {
+	metrics, ok := c.resources[key]
+	if !ok {
+		metrics = make(map[string]*metricspb.Metric)
+		c.resources[key] = metrics
+		c.order = append(c.order, key)
+	}
+
+	if m, ok := metrics[mf.GetName()]; ok {
+		return m
+	}
+
+	m := &metricspb.Metric{
+		Name:        mf.GetName(),
+		Description: mf.GetHelp(),
+	}
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		m.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	case dto.MetricType_SUMMARY:
+		m.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{}}
+	case dto.MetricType_HISTOGRAM:
+		m.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}}
+	default:
+		m.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+	}
+	metrics[mf.GetName()] = m
+
+	return m
+}
+
+func (c *converter) addDataPoint(key resourceKey, mf *dto.MetricFamily, m *dto.Metric, attrs []*commonpb.KeyValue) {
+	metric := c.metricFor(key, mf)
+
+	ts := c.now
+	if m.TimestampMs != nil {
+		ts = uint64(m.GetTimestampMs()) * 1e6
+	}
+
+	switch d := metric.Data.(type) {
+	case *metricspb.Metric_Sum:
+		d.Sum.DataPoints = append(d.Sum.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: c.startTime,
+			TimeUnixNano:      ts,
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()},
+		})
+	case *metricspb.Metric_Gauge:
+		v := m.GetGauge().GetValue()
+		if mf.GetType() == dto.MetricType_UNTYPED {
+			v = m.GetUntyped().GetValue()
+		}
+		d.Gauge.DataPoints = append(d.Gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: v},
+		})
+	case *metricspb.Metric_Summary:
+		s := m.GetSummary()
+		dp := &metricspb.SummaryDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: c.startTime,
+			TimeUnixNano:      ts,
+			Count:             s.GetSampleCount(),
+			Sum:               s.GetSampleSum(),
+		}
+		for _, q := range s.GetQuantile() {
+			dp.QuantileValues = append(dp.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+				Quantile: q.GetQuantile(),
+				Value:    q.GetValue(),
+			})
+		}
+		d.Summary.DataPoints = append(d.Summary.DataPoints, dp)
+	case *metricspb.Metric_Histogram:
+		h := m.GetHistogram()
+		sum := h.GetSampleSum()
+		dp := &metricspb.HistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: c.startTime,
+			TimeUnixNano:      ts,
+			Count:             h.GetSampleCount(),
+			Sum:               &sum,
+		}
+		// prometheus buckets are cumulative, OTLP bucket counts are not
+		var prev uint64
+		for _, b := range h.GetBucket() {
+			dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+			dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prev)
+			prev = b.GetCumulativeCount()
+		}
+		dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prev)
+		d.Histogram.DataPoints = append(d.Histogram.DataPoints, dp)
+	}
+}
+
+func (c *converter) resourceMetrics() []*metricspb.ResourceMetrics {
+	rms := make([]*metricspb.ResourceMetrics, 0, len(c.order))
+
+	for _, key := range c.order {
+		metrics := c.resources[key]
+		names := make([]string, 0, len(metrics))
+		for n := range metrics {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		sm := &metricspb.ScopeMetrics{Scope: c.scope}
+		for _, n := range names {
+			sm.Metrics = append(sm.Metrics, metrics[n])
+		}
+
+		rms = append(rms, &metricspb.ResourceMetrics{
+			Resource:     &resourcepb.Resource{Attributes: c.resourceAttributes(key)},
+			ScopeMetrics: []*metricspb.ScopeMetrics{sm},
+		})
+	}
+
+	return rms
+}
+
+func (c *converter) resourceAttributes(key resourceKey) []*commonpb.KeyValue {
+	attrs := []*commonpb.KeyValue{stringAttribute("service.name", serviceName)}
+	if key.device == "" {
+		return attrs
+	}
+
+	attrs = append(attrs, stringAttribute("device", key.device))
+	if key.address != "" {
+		attrs = append(attrs, stringAttribute("address", key.address))
+	}
+
+	id := c.identity(key.device)
+	if id.board != "" {
+		attrs = append(attrs, stringAttribute("board", id.board))
+	}
+	if id.version != "" {
+		attrs = append(attrs, stringAttribute("version", id.version))
+	}
+
+	return attrs
+}
+
+func isDeviceMetric(name string) bool {
+	return strings.HasPrefix(name, metricsPrefix) && !strings.HasPrefix(name, selfPrefix)
+}
+
+// isResourceMetric reports whether the metric comes from the system resource
+// collector, which labels its metrics with board name and RouterOS version
+func isResourceMetric(name string, labels map[string]string) bool {
+	_, ok := labels["boardname"]
+	return ok && strings.HasPrefix(name, systemPrefix)
+}
+
+func deviceFromLabels(labels map[string]string) (string, string) {
+	for _, l := range collector.DeviceLabelNames {
+		if v := labels[l]; v != "" {
+			return l, v
+		}
+	}
+
+	return "", ""
+}
+
+func labelMap(pairs []*dto.LabelPair) map[string]string {
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		m[p.GetName()] = p.GetValue()
+	}
+
+	return m
+}
+
+func attributes(pairs []*dto.LabelPair, skip map[string]bool) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(pairs))
+	for _, p := range pairs {
+		if skip[p.GetName()] {
+			continue
+		}
+		attrs = append(attrs, stringAttribute(p.GetName(), p.GetValue()))
+	}
+
+	return attrs
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
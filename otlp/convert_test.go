@@ -0,0 +1,103 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func labelPairs(kv ...string) []*dto.LabelPair {
+	pairs := []*dto.LabelPair{}
+	for i := 0; i < len(kv); i += 2 {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(kv[i]), Value: proto.String(kv[i+1])})
+	}
+
+	return pairs
+}
+
+func attributeMap(attrs []*commonpb.KeyValue) map[string]string {
+	m := make(map[string]string)
+	for _, a := range attrs {
+		m[a.Key] = a.Value.GetStringValue()
+	}
+
+	return m
+}
+
+func TestConvert(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("mikrotik_interface_rx_byte"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   labelPairs("address", "10.0.0.1", "interface", "ether1", "name", "router1"),
+					Counter: &dto.Counter{Value: proto.Float64(42)},
+				},
+			},
+		},
+		{
+			Name: proto.String("mikrotik_system_cpu_load"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: labelPairs("address", "10.0.0.1", "boardname", "CCR1009", "name", "router1", "version", "6.48"),
+					Gauge: &dto.Gauge{Value: proto.Float64(7)},
+				},
+			},
+		},
+		{
+			Name: proto.String("mikrotik_system_package"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: labelPairs("devicename", "router1", "name", "routeros", "version", "6.48.1"),
+					Gauge: &dto.Gauge{Value: proto.Float64(1)},
+				},
+			},
+		},
+		{
+			Name: proto.String("go_goroutines"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{Gauge: &dto.Gauge{Value: proto.Float64(10)}},
+			},
+		},
+	}
+
+	rms := newConverter(1, 2, "test").convert(mfs)
+	assert.Len(t, rms, 2)
+
+	router := rms[0]
+	assert.Equal(t, map[string]string{
+		"service.name": "mikrotik-exporter",
+		"device":       "router1",
+		"address":      "10.0.0.1",
+		"board":        "CCR1009",
+		"version":      "6.48",
+	}, attributeMap(router.Resource.Attributes))
+
+	metrics := router.ScopeMetrics[0].Metrics
+	assert.Len(t, metrics, 3)
+
+	rx := metrics[0]
+	assert.Equal(t, "mikrotik_interface_rx_byte", rx.Name)
+	sum := rx.GetSum()
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality)
+	assert.Equal(t, map[string]string{"interface": "ether1"}, attributeMap(sum.DataPoints[0].Attributes))
+	assert.Equal(t, float64(42), sum.DataPoints[0].GetAsDouble())
+
+	cpu := metrics[1].GetGauge()
+	assert.Empty(t, cpu.DataPoints[0].Attributes)
+
+	pkg := metrics[2].GetGauge()
+	assert.Equal(t, map[string]string{"name": "routeros", "version": "6.48.1"}, attributeMap(pkg.DataPoints[0].Attributes))
+
+	self := rms[1]
+	assert.Equal(t, map[string]string{"service.name": "mikrotik-exporter"}, attributeMap(self.Resource.Attributes))
+}
@@ -0,0 +1,197 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// ProtocolGRPC exports via OTLP/gRPC
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP exports via OTLP/HTTP with protobuf payloads
+	ProtocolHTTP = "http"
+
+	// DefaultInterval defines how often metrics are collected and exported
+	DefaultInterval = 60 * time.Second
+	// DefaultTimeout defines the timeout of a single export request
+	DefaultTimeout = 10 * time.Second
+
+	defaultHTTPPath = "/v1/metrics"
+)
+
+// Exporter periodically gathers metrics and exports them via OTLP
+type Exporter struct {
+	cfg       config.OTLP
+	gatherer  prometheus.Gatherer
+	version   string
+	startTime time.Time
+	send      func(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) error
+}
+
+// New creates an Exporter for the given configuration
+func New(cfg config.OTLP, g prometheus.Gatherer, version string) (*Exporter, error) {
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolGRPC
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	e := &Exporter{
+		cfg:       cfg,
+		gatherer:  g,
+		version:   version,
+		startTime: time.Now(),
+	}
+
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		send, err := grpcSender(cfg)
+		if err != nil {
+			return nil, err
+		}
+		e.send = send
+	case ProtocolHTTP:
+		e.send = httpSender(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", cfg.Protocol)
+	}
+
+	return e, nil
+}
+
+// Run collects and exports metrics every interval. It never returns.
+func (e *Exporter) Run() {
+	log.WithFields(log.Fields{
+		"endpoint": e.cfg.Endpoint,
+		"protocol": e.cfg.Protocol,
+		"interval": e.cfg.Interval,
+	}).Info("starting OTLP export")
+
+	e.export()
+
+	t := time.NewTicker(e.cfg.Interval)
+	defer t.Stop()
+	for range t.C {
+		e.export()
+	}
+}
+
+func (e *Exporter) export() {
+	now := time.Now()
+	mfs, err := e.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect along with the error
+		log.WithField("error", err).Warn("error gathering metrics for OTLP export")
+	}
+
+	c := newConverter(uint64(e.startTime.UnixNano()), uint64(now.UnixNano()), e.version)
+	req := &collectorpb.ExportMetricsServiceRequest{ResourceMetrics: c.convert(mfs)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	defer cancel()
+
+	err = e.send(ctx, req)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"endpoint": e.cfg.Endpoint,
+			"error":    err,
+		}).Error("error exporting metrics via OTLP")
+		return
+	}
+
+	log.WithField("resources", len(req.ResourceMetrics)).Debug("exported metrics via OTLP")
+}
+
+func grpcSender(cfg config.OTLP) (func(context.Context, *collectorpb.ExportMetricsServiceRequest) error, error) {
+	opts := []grpc.DialOption{}
+	if cfg.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	}
+
+	conn, err := grpc.Dial(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client := collectorpb.NewMetricsServiceClient(conn)
+
+	return func(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) error {
+		if len(cfg.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(cfg.Headers))
+		}
+
+		_, err := client.Export(ctx, req)
+		return err
+	}, nil
+}
+
+func httpSender(cfg config.OTLP) func(context.Context, *collectorpb.ExportMetricsServiceRequest) error {
+	url := cfg.Endpoint
+	if !strings.Contains(url, "://") {
+		scheme := "https://"
+		if cfg.Insecure {
+			scheme = "http://"
+		}
+		url = scheme + url
+	}
+	if !strings.HasSuffix(url, defaultHTTPPath) {
+		url = strings.TrimRight(url, "/") + defaultHTTPPath
+	}
+
+	client := &http.Client{}
+
+	return func(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) error {
+		b, err := proto.Marshal(req)
+		if err != nil {
+			return err
+		}
+
+		r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		r = r.WithContext(ctx)
+		r.Header.Set("Content-Type", "application/x-protobuf")
+		r.Header.Set("User-Agent", "mikrotik-exporter")
+		for k, v := range cfg.Headers {
+			r.Header.Set(k, v)
+		}
+
+		resp, err := client.Do(r)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+		}
+
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+type timeSeries struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// seriesFromFamilies flattens gathered metric families into remote_write
+// time series. Summaries and histograms are expanded into their _sum, _count
+// and quantile/bucket series the same way the text exposition format does.
+func seriesFromFamilies(mfs []*dto.MetricFamily, externalLabels map[string]string, ts int64) []timeSeries {
+	series := make([]timeSeries, 0)
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			t := ts
+			if m.TimestampMs != nil {
+				t = m.GetTimestampMs()
+			}
+
+			add := func(name string, v float64, extra ...label) {
+				series = append(series, timeSeries{
+					labels:    labelsFor(name, m.GetLabel(), externalLabels, extra...),
+					value:     v,
+					timestamp: t,
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return series
+}
+
+func labelsFor(name string, pairs []*dto.LabelPair, externalLabels map[string]string, extra ...label) []label {
+	labels := make([]label, 0, len(pairs)+len(externalLabels)+len(extra)+1)
+	labels = append(labels, label{"__name__", name})
+
+	seen := make(map[string]bool)
+	for _, p := range pairs {
+		labels = append(labels, label{p.GetName(), p.GetValue()})
+		seen[p.GetName()] = true
+	}
+	for _, l := range extra {
+		labels = append(labels, l)
+		seen[l.name] = true
+	}
+
+	// external labels never override labels set by the exporter itself
+	for k, v := range externalLabels {
+		if !seen[k] {
+			labels = append(labels, label{k, v})
+		}
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+
+	return labels
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest serializes the series as a prometheus.WriteRequest
+// protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	buf := make([]byte, 0)
+	for _, s := range series {
+		buf = appendBytesField(buf, 1, encodeTimeSeries(s))
+	}
+
+	return buf
+}
+
+func encodeTimeSeries(s timeSeries) []byte {
+	buf := make([]byte, 0)
+	for _, l := range s.labels {
+		lb := appendBytesField(nil, 1, []byte(l.name))
+		lb = appendBytesField(lb, 2, []byte(l.value))
+		buf = appendBytesField(buf, 1, lb)
+	}
+
+	sb := appendKey(nil, 1, 1)
+	sb = appendFixed64(sb, math.Float64bits(s.value))
+	sb = appendKey(sb, 2, 0)
+	sb = appendVarint(sb, uint64(s.timestamp))
+
+	return appendBytesField(buf, 2, sb)
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+	buf = appendKey(buf, field, 2)
+	buf = appendVarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendKey(buf []byte, field, wireType int) []byte {
+	return appendVarint(buf, uint64(field<<3|wireType))
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendFixed64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
@@ -0,0 +1,61 @@
+package remotewrite
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesFromFamilies(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("mikrotik_interface_rx_byte"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{
+						{Name: proto.String("name"), Value: proto.String("router1")},
+						{Name: proto.String("interface"), Value: proto.String("ether1")},
+					},
+					Counter: &dto.Counter{Value: proto.Float64(42)},
+				},
+			},
+		},
+	}
+
+	series := seriesFromFamilies(mfs, map[string]string{"site": "a", "name": "ignored"}, 1000)
+
+	assert.Len(t, series, 1)
+	assert.Equal(t, []label{
+		{"__name__", "mikrotik_interface_rx_byte"},
+		{"interface", "ether1"},
+		{"name", "router1"},
+		{"site", "a"},
+	}, series[0].labels)
+	assert.Equal(t, float64(42), series[0].value)
+	assert.Equal(t, int64(1000), series[0].timestamp)
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	series := []timeSeries{
+		{
+			labels:    []label{{"__name__", "up"}},
+			value:     1,
+			timestamp: 1,
+		},
+	}
+
+	expected := []byte{
+		0x0a, 0x1d, // timeseries, 29 bytes
+		0x0a, 0x0e, // label, 14 bytes
+		0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_',
+		0x12, 0x02, 'u', 'p',
+		0x12, 0x0b, // sample, 11 bytes
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+		0x10, 0x01,
+	}
+
+	assert.Equal(t, expected, encodeWriteRequest(series))
+}
@@ -0,0 +1,259 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultInterval defines how often metrics are collected and pushed
+	DefaultInterval = 60 * time.Second
+	// DefaultTimeout defines the timeout of a single remote_write request
+	DefaultTimeout = 10 * time.Second
+
+	defaultMaxRetries    = 3
+	defaultQueueCapacity = 10
+	minBackoff           = 1 * time.Second
+	maxBackoff           = 30 * time.Second
+)
+
+var (
+	sentSamples = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "mikrotik_exporter",
+		Subsystem: "remote_write",
+		Name:      "sent_samples_total",
+		Help:      "number of samples successfully pushed via remote_write",
+	})
+	failedBatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "mikrotik_exporter",
+		Subsystem: "remote_write",
+		Name:      "failed_batches_total",
+		Help:      "number of batches that could not be pushed after all retries",
+	})
+	droppedBatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "mikrotik_exporter",
+		Subsystem: "remote_write",
+		Name:      "dropped_batches_total",
+		Help:      "number of batches dropped because the queue was full",
+	})
+)
+
+// Pusher periodically gathers metrics and pushes them to a remote_write endpoint
+type Pusher struct {
+	cfg      config.RemoteWrite
+	gatherer prometheus.Gatherer
+	client   *http.Client
+
+	mu    sync.Mutex
+	queue []*batch
+	ready chan struct{}
+}
+
+type batch struct {
+	data    []byte
+	samples int
+}
+
+// New creates a Pusher for the given configuration
+func New(cfg config.RemoteWrite, g prometheus.Gatherer) *Pusher {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.QueueCapacity == 0 {
+		cfg.QueueCapacity = defaultQueueCapacity
+	}
+
+	return &Pusher{
+		cfg:      cfg,
+		gatherer: g,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (p *Pusher) Describe(ch chan<- *prometheus.Desc) {
+	sentSamples.Describe(ch)
+	failedBatches.Describe(ch)
+	droppedBatches.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (p *Pusher) Collect(ch chan<- prometheus.Metric) {
+	sentSamples.Collect(ch)
+	failedBatches.Collect(ch)
+	droppedBatches.Collect(ch)
+}
+
+// Run collects and enqueues metrics every interval and ships the queued
+// batches in the background. It never returns.
+func (p *Pusher) Run() {
+	log.WithFields(log.Fields{
+		"url":      p.cfg.URL,
+		"interval": p.cfg.Interval,
+	}).Info("starting remote_write push")
+
+	go p.sendLoop()
+
+	p.collectAndEnqueue()
+
+	t := time.NewTicker(p.cfg.Interval)
+	defer t.Stop()
+	for range t.C {
+		p.collectAndEnqueue()
+	}
+}
+
+func (p *Pusher) collectAndEnqueue() {
+	now := time.Now()
+	mfs, err := p.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect along with the error
+		log.WithField("error", err).Warn("error gathering metrics for remote_write")
+	}
+
+	series := seriesFromFamilies(mfs, p.cfg.ExternalLabels, now.UnixNano()/int64(time.Millisecond))
+	if len(series) == 0 {
+		return
+	}
+
+	p.enqueue(&batch{
+		data:    snappy.Encode(nil, encodeWriteRequest(series)),
+		samples: len(series),
+	})
+	log.WithField("samples", len(series)).Debug("queued remote_write batch")
+}
+
+// enqueue appends a batch, dropping the oldest one if the queue is full
+func (p *Pusher) enqueue(b *batch) {
+	p.mu.Lock()
+	if len(p.queue) >= p.cfg.QueueCapacity {
+		p.queue = p.queue[1:]
+		droppedBatches.Inc()
+		log.Warn("remote_write queue full, dropping oldest batch")
+	}
+	p.queue = append(p.queue, b)
+	p.mu.Unlock()
+
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pusher) dequeue() *batch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queue) == 0 {
+		return nil
+	}
+	b := p.queue[0]
+	p.queue = p.queue[1:]
+
+	return b
+}
+
+func (p *Pusher) sendLoop() {
+	for range p.ready {
+		for b := p.dequeue(); b != nil; b = p.dequeue() {
+			p.sendWithRetries(b)
+		}
+	}
+}
+
+func (p *Pusher) sendWithRetries(b *batch) {
+	backoff := minBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := p.send(b.data)
+		if err == nil {
+			sentSamples.Add(float64(b.samples))
+			return
+		}
+
+		if !err.recoverable || attempt >= p.cfg.MaxRetries {
+			failedBatches.Inc()
+			log.WithFields(log.Fields{
+				"url":      p.cfg.URL,
+				"attempts": attempt + 1,
+				"error":    err,
+			}).Error("error pushing metrics via remote_write")
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"url":     p.cfg.URL,
+			"attempt": attempt + 1,
+			"backoff": backoff,
+			"error":   err,
+		}).Warn("remote_write push failed, retrying")
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+type recoverableError struct {
+	err         error
+	recoverable bool
+}
+
+func (e *recoverableError) Error() string {
+	return e.err.Error()
+}
+
+func (p *Pusher) send(b []byte) *recoverableError {
+	req, err := http.NewRequest(http.MethodPost, p.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return &recoverableError{err, false}
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "mikrotik-exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	if p.cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(p.cfg.BasicAuth.Username, p.cfg.BasicAuth.Password)
+	} else if p.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.BearerToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return &recoverableError{err, true}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+
+	// only server side errors and rate limiting are worth retrying
+	return &recoverableError{err, resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests}
+}
@@ -0,0 +1,131 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severities as defined in RFC 5424
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var (
+	rfc3164Timestamp = regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2} `)
+	routerOSTopics   = regexp.MustCompile(`^[a-z0-9-]+(,[a-z0-9-]+)+ `)
+)
+
+// Message is a decoded syslog message
+type Message struct {
+	Severity int
+	Hostname string
+	Tag      string
+	Text     string
+}
+
+// SeverityName returns the keyword of the message severity
+func (m *Message) SeverityName() string {
+	return severities[m.Severity]
+}
+
+// Parse decodes a RFC 5424 or RFC 3164 message. Messages sent by RouterOS
+// without BSD syslog format consist of the topics and the text only.
+func Parse(b []byte) (*Message, error) {
+	b = bytes.TrimRight(b, "\r\n\x00")
+	if len(b) < 3 || b[0] != '<' {
+		return nil, fmt.Errorf("missing priority")
+	}
+
+	end := bytes.IndexByte(b, '>')
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(string(b[1:end]))
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("invalid priority %q", b[1:end])
+	}
+
+	m := &Message{Severity: pri & 7}
+	rest := string(b[end+1:])
+
+	switch {
+	case strings.HasPrefix(rest, "1 "):
+		parseRFC5424(m, rest[2:])
+	case rfc3164Timestamp.MatchString(rest):
+		parseRFC3164(m, rest[len("Jan  2 15:04:05 "):])
+	default:
+		if loc := routerOSTopics.FindStringIndex(rest); loc != nil {
+			m.Tag = rest[:loc[1]-1]
+			rest = rest[loc[1]:]
+		}
+		m.Text = rest
+	}
+
+	return m, nil
+}
+
+func parseRFC5424(m *Message, s string) {
+	// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+	fields := strings.SplitN(s, " ", 6)
+	if len(fields) < 6 {
+		m.Text = s
+		return
+	}
+
+	m.Hostname = nilValue(fields[1])
+	m.Tag = nilValue(fields[2])
+	m.Text = strings.TrimPrefix(skipStructuredData(fields[5]), "\xef\xbb\xbf")
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+
+	return s
+}
+
+// skipStructuredData returns the message following the structured data
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(s[1:], " ")
+	}
+
+	inElement, escaped := false, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '[':
+			inElement = true
+		case r == ']':
+			inElement = false
+		case !inElement:
+			return strings.TrimPrefix(s[i:], " ")
+		}
+	}
+
+	return ""
+}
+
+func parseRFC3164(m *Message, s string) {
+	// HOSTNAME TAG: MSG
+	fields := strings.SplitN(s, " ", 2)
+	m.Hostname = fields[0]
+	if len(fields) < 2 {
+		return
+	}
+
+	s = fields[1]
+	if i := strings.Index(s, ": "); i > 0 && !strings.Contains(s[:i], " ") {
+		m.Tag = strings.SplitN(s[:i], "[", 2)[0]
+		s = s[i+2:]
+	} else if loc := routerOSTopics.FindStringIndex(s); loc != nil {
+		m.Tag = s[:loc[1]-1]
+		s = s[loc[1]:]
+	}
+	m.Text = s
+}
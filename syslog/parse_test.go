@@ -0,0 +1,104 @@
+package syslog
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	var testCases = []struct {
+		input    string
+		severity string
+		hostname string
+		tag      string
+		text     string
+	}{
+		{
+			"<30>system,info,account user admin logged in from 10.0.0.5 via ssh",
+			"info", "", "system,info,account", "user admin logged in from 10.0.0.5 via ssh",
+		},
+		{
+			"<28>Oct 18 12:00:00 core1 system,error,critical login failure for user admin from 10.0.0.5 via ssh\n",
+			"warning", "core1", "system,error,critical", "login failure for user admin from 10.0.0.5 via ssh",
+		},
+		{
+			"<134>Oct  8 01:02:03 edge1 dhcp[42]: offering lease 10.0.0.7 without success",
+			"info", "edge1", "dhcp", "offering lease 10.0.0.7 without success",
+		},
+		{
+			`<187>1 2023-10-18T12:00:00.000Z core2 interface - - [meta sequenceId="1" x="a\]b"] ether1 link down`,
+			"err", "core2", "interface", "ether1 link down",
+		},
+		{
+			"<14>1 2023-10-18T12:00:00Z core2 system - - - \xef\xbb\xbfrouter rebooted",
+			"info", "core2", "system", "router rebooted",
+		},
+	}
+
+	for _, testCase := range testCases {
+		m, err := Parse([]byte(testCase.input))
+		assert.NoError(t, err, testCase.input)
+		assert.Equal(t, testCase.severity, m.SeverityName(), testCase.input)
+		assert.Equal(t, testCase.hostname, m.Hostname, testCase.input)
+		assert.Equal(t, testCase.tag, m.Tag, testCase.input)
+		assert.Equal(t, testCase.text, m.Text, testCase.input)
+	}
+
+	for _, input := range []string{"", "no priority", "<999>too high", "<abc>x"} {
+		_, err := Parse([]byte(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("11 <30>foo bar<30>newline framed\n"))
+
+	b, err := readFrame(br)
+	assert.NoError(t, err)
+	assert.Equal(t, "<30>foo bar", string(b))
+
+	b, err = readFrame(br)
+	assert.NoError(t, err)
+	assert.Equal(t, "<30>newline framed\n", string(b))
+
+	// frames without delimiter must not be buffered without limit
+	br = bufio.NewReader(strings.NewReader("<30>" + strings.Repeat("x", maxMessageSize)))
+	_, err = readFrame(br)
+	assert.Equal(t, errFrameTooLarge, err)
+
+	br = bufio.NewReader(strings.NewReader(strings.Repeat("1", maxFrameLengthSize+1) + " <30>x"))
+	_, err = readFrame(br)
+	assert.Equal(t, errFrameTooLarge, err)
+}
+
+func TestReceiverCount(t *testing.T) {
+	r, err := New(config.Syslog{Rules: []config.LogPattern{
+		{Name: "login_failure", Regex: "login failure for user"},
+	}}, []config.Device{
+		{Name: "core1", Address: "10.0.0.1"},
+		{Name: "core2", Address: "10.0.0.2"},
+	})
+	assert.NoError(t, err)
+
+	m, _ := Parse([]byte("<28>system,error,critical login failure for user admin from 10.0.0.5 via ssh"))
+	r.count(m, "10.0.0.1")
+	r.count(m, "10.0.0.1")
+
+	// mapped by hostname
+	m, _ = Parse([]byte("<30>Oct 18 12:00:00 CORE2 system,info router rebooted"))
+	r.count(m, "192.168.0.2")
+
+	m, _ = Parse([]byte("<30>system,info router rebooted"))
+	r.count(m, "192.168.0.3")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(r.matches.WithLabelValues("core1", "10.0.0.1", "login_failure")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(r.messages.WithLabelValues("core1", "10.0.0.1", "warning")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.messages.WithLabelValues("core2", "10.0.0.2", "info")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.unknown))
+}
@@ -0,0 +1,284 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultListen is the address syslog messages are received on when
+// neither UDP nor TCP is configured
+const DefaultListen = ":514"
+
+const maxMessageSize = 64 * 1024
+
+// maxFrameLengthSize limits the octet count prefix of a TCP frame
+const maxFrameLengthSize = 10
+
+var errFrameTooLarge = errors.New("syslog frame exceeds maximum message size")
+
+type rule struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// Receiver receives syslog messages of the configured devices and counts
+// them by severity and matching rule
+type Receiver struct {
+	cfg     config.Syslog
+	rules   []rule
+	byIP    map[string]config.Device
+	byName  map[string]config.Device
+	unknown prometheus.Counter
+
+	messages *prometheus.CounterVec
+	matches  *prometheus.CounterVec
+}
+
+// New creates a receiver mapping senders to the given devices
+func New(cfg config.Syslog, devices []config.Device) (*Receiver, error) {
+	if cfg.ListenUDP == "" && cfg.ListenTCP == "" {
+		cfg.ListenUDP = DefaultListen
+	}
+
+	r := &Receiver{
+		cfg:    cfg,
+		byIP:   make(map[string]config.Device),
+		byName: make(map[string]config.Device),
+		unknown: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "mikrotik_exporter",
+			Subsystem: "syslog",
+			Name:      "unknown_sender_messages_total",
+			Help:      "number of syslog messages which could not be mapped to a device",
+		}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mikrotik",
+			Subsystem: "syslog",
+			Name:      "messages_total",
+			Help:      "number of syslog messages received by severity",
+		}, []string{"name", "address", "severity"}),
+		matches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mikrotik",
+			Subsystem: "syslog",
+			Name:      "rule_matches_total",
+			Help:      "number of syslog messages matching the configured rule",
+		}, []string{"name", "address", "rule"}),
+	}
+
+	for _, ru := range cfg.Rules {
+		re, err := regexp.Compile(ru.Regex)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, rule{name: ru.Name, regex: re})
+	}
+
+	for _, d := range devices {
+		r.byName[strings.ToLower(d.Name)] = d
+		if d.Address == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(d.Address); ip != nil {
+			r.byIP[ip.String()] = d
+			continue
+		}
+
+		addrs, err := net.LookupHost(d.Address)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":  d.Name,
+				"address": d.Address,
+				"error":   err,
+			}).Warn("could not resolve device address for syslog")
+			continue
+		}
+		for _, a := range addrs {
+			r.byIP[net.ParseIP(a).String()] = d
+		}
+	}
+
+	return r, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *Receiver) Describe(ch chan<- *prometheus.Desc) {
+	r.unknown.Describe(ch)
+	r.messages.Describe(ch)
+	r.matches.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (r *Receiver) Collect(ch chan<- prometheus.Metric) {
+	r.unknown.Collect(ch)
+	r.messages.Collect(ch)
+	r.matches.Collect(ch)
+}
+
+// Run receives messages on the configured listeners until an error occurs
+func (r *Receiver) Run() error {
+	errs := make(chan error, 2)
+
+	if r.cfg.ListenUDP != "" {
+		conn, err := net.ListenPacket("udp", r.cfg.ListenUDP)
+		if err != nil {
+			return err
+		}
+		log.WithField("listen", r.cfg.ListenUDP).Info("listening for syslog messages via UDP")
+		go func() {
+			errs <- r.serveUDP(conn)
+		}()
+	}
+
+	if r.cfg.ListenTCP != "" {
+		l, err := net.Listen("tcp", r.cfg.ListenTCP)
+		if err != nil {
+			return err
+		}
+		log.WithField("listen", r.cfg.ListenTCP).Info("listening for syslog messages via TCP")
+		go func() {
+			errs <- r.serveTCP(l)
+		}()
+	}
+
+	return <-errs
+}
+
+func (r *Receiver) serveUDP(conn net.PacketConn) error {
+	defer conn.Close()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		r.handle(buf[:n], addr)
+	}
+}
+
+func (r *Receiver) serveTCP(l net.Listener) error {
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go r.serveConn(conn)
+	}
+}
+
+// serveConn reads messages framed by octet counting (RFC 6587) or
+// terminated by newlines
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		b, err := readFrame(br)
+		if err != nil {
+			if err != io.EOF {
+				log.WithFields(log.Fields{
+					"source": conn.RemoteAddr().String(),
+					"error":  err,
+				}).Debug("error reading syslog message")
+			}
+			return
+		}
+
+		r.handle(b, conn.RemoteAddr())
+	}
+}
+
+func readFrame(br *bufio.Reader) ([]byte, error) {
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] < '0' || first[0] > '9' {
+		return readDelimited(br, '\n', maxMessageSize)
+	}
+
+	l, err := readDelimited(br, ' ', maxFrameLengthSize)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(l)))
+	if err != nil || n > maxMessageSize {
+		return nil, errFrameTooLarge
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(br, b)
+	return b, err
+}
+
+// readDelimited reads up to and including delim, failing if more than limit
+// bytes are read without finding it
+func readDelimited(br *bufio.Reader, delim byte, limit int) ([]byte, error) {
+	var b []byte
+	for {
+		chunk, err := br.ReadSlice(delim)
+		if len(b)+len(chunk) > limit {
+			return nil, errFrameTooLarge
+		}
+		b = append(b, chunk...)
+
+		if err != bufio.ErrBufferFull {
+			return b, err
+		}
+	}
+}
+
+func (r *Receiver) handle(b []byte, addr net.Addr) {
+	src := ""
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		src = a.IP.String()
+	case *net.TCPAddr:
+		src = a.IP.String()
+	}
+
+	m, err := Parse(b)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"source": src,
+			"error":  err,
+		}).Debug("error parsing syslog message")
+		return
+	}
+
+	r.count(m, src)
+}
+
+func (r *Receiver) count(m *Message, src string) {
+	d, ok := r.byIP[src]
+	if !ok {
+		d, ok = r.byName[strings.ToLower(m.Hostname)]
+	}
+	if !ok {
+		r.unknown.Inc()
+		return
+	}
+
+	r.messages.WithLabelValues(d.Name, d.Address, m.SeverityName()).Inc()
+
+	for _, ru := range r.rules {
+		if ru.regex.MatchString(m.Text) {
+			r.matches.WithLabelValues(d.Name, d.Address, ru.name).Inc()
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	neighborInfoMetric  = "mikrotik_neighbor_info"
+	scrapeSuccessMetric = "mikrotik_scrape_collector_success"
+)
+
+// Graph is the network topology of all scraped devices
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is a scraped device
+type Node struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Up      bool   `json:"up"`
+}
+
+// Edge connects two devices which see each other as neighbors
+type Edge struct {
+	Source          string   `json:"source"`
+	SourceInterface string   `json:"source_interface,omitempty"`
+	Target          string   `json:"target"`
+	TargetInterface string   `json:"target_interface,omitempty"`
+	Protocols       []string `json:"protocols,omitempty"`
+}
+
+// Build assembles the graph from the scrape success and neighbor metrics.
+// Neighbors which are not scraped devices themselves are left out.
+func Build(mfs []*dto.MetricFamily) *Graph {
+	nodes := make(map[string]*Node)
+	neighbors := []map[string]string{}
+
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case scrapeSuccessMetric:
+			for _, m := range mf.GetMetric() {
+				l := labels(m)
+				n := node(nodes, l["device"])
+				n.Up = m.GetGauge().GetValue() == 1
+			}
+		case neighborInfoMetric:
+			for _, m := range mf.GetMetric() {
+				l := labels(m)
+				n := node(nodes, l["name"])
+				if n.Address == "" {
+					n.Address = l["address"]
+				}
+				neighbors = append(neighbors, l)
+			}
+		}
+	}
+	delete(nodes, "")
+
+	g := &Graph{}
+	for _, l := range neighbors {
+		remote := resolve(nodes, l["identity"], l["neighbor_address"])
+		if remote == "" || remote == l["name"] {
+			continue
+		}
+
+		g.addEdge(Edge{
+			Source:          l["name"],
+			SourceInterface: l["interface"],
+			Target:          remote,
+			TargetInterface: l["remote_interface"],
+			Protocols:       splitProtocols(l["protocol"]),
+		})
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Name < g.Nodes[j].Name
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.SourceInterface != b.SourceInterface {
+			return a.SourceInterface < b.SourceInterface
+		}
+		return a.Target < b.Target
+	})
+
+	return g
+}
+
+func labels(m *dto.Metric) map[string]string {
+	res := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		res[l.GetName()] = l.GetValue()
+	}
+
+	return res
+}
+
+func node(nodes map[string]*Node, name string) *Node {
+	n, ok := nodes[name]
+	if !ok {
+		n = &Node{Name: name}
+		nodes[name] = n
+	}
+
+	return n
+}
+
+// resolve returns the name of the device matching the neighbor identity or
+// address
+func resolve(nodes map[string]*Node, identity, address string) string {
+	if _, ok := nodes[identity]; ok && identity != "" {
+		return identity
+	}
+
+	if address == "" {
+		return ""
+	}
+	for _, n := range nodes {
+		if n.Address == address {
+			return n.Name
+		}
+	}
+
+	return ""
+}
+
+func splitProtocols(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	res := strings.Split(s, ",")
+	sort.Strings(res)
+
+	return res
+}
+
+// addEdge adds the edge unless the link was already seen, from the other side
+// or another neighbor entry on the same side, in which case the missing
+// interface names are filled in
+func (g *Graph) addEdge(e Edge) {
+	for i := range g.Edges {
+		ex := &g.Edges[i]
+
+		switch {
+		case ex.Source == e.Source && ex.Target == e.Target &&
+			matchInterface(ex.SourceInterface, e.SourceInterface) &&
+			matchInterface(ex.TargetInterface, e.TargetInterface):
+			ex.merge(e.SourceInterface, e.TargetInterface, e.Protocols)
+			return
+		case ex.Source == e.Target && ex.Target == e.Source &&
+			matchInterface(ex.SourceInterface, e.TargetInterface) &&
+			matchInterface(ex.TargetInterface, e.SourceInterface):
+			ex.merge(e.TargetInterface, e.SourceInterface, e.Protocols)
+			return
+		}
+	}
+
+	g.Edges = append(g.Edges, e)
+}
+
+func (e *Edge) merge(sourceInterface, targetInterface string, protocols []string) {
+	if e.SourceInterface == "" {
+		e.SourceInterface = sourceInterface
+	}
+	if e.TargetInterface == "" {
+		e.TargetInterface = targetInterface
+	}
+	e.Protocols = mergeProtocols(e.Protocols, protocols)
+}
+
+func matchInterface(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+func mergeProtocols(a, b []string) []string {
+	seen := make(map[string]bool)
+	res := []string{}
+	for _, p := range append(append([]string{}, a...), b...) {
+		if !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// WriteDOT renders the graph in the Graphviz DOT language
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("graph mikrotik {\n")
+
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Address != "" {
+			label += "\n" + n.Address
+		}
+		style := ""
+		if !n.Up {
+			style = ", color=red"
+		}
+		fmt.Fprintf(b, "  %s [label=%s%s];\n", quote(n.Name), quote(label), style)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "  %s -- %s [taillabel=%s, headlabel=%s];\n",
+			quote(e.Source), quote(e.Target), quote(e.SourceInterface), quote(e.TargetInterface))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
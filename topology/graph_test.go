@@ -0,0 +1,81 @@
+package topology
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func gauge(value float64, kv ...string) *dto.Metric {
+	m := &dto.Metric{Gauge: &dto.Gauge{Value: proto.Float64(value)}}
+	for i := 0; i < len(kv); i += 2 {
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(kv[i]), Value: proto.String(kv[i+1])})
+	}
+
+	return m
+}
+
+func neighbor(name, address, iface, identity, neighborAddress, remoteInterface, protocol string) *dto.Metric {
+	return gauge(1, "name", name, "address", address, "interface", iface, "identity", identity,
+		"neighbor_address", neighborAddress, "remote_interface", remoteInterface, "protocol", protocol)
+}
+
+func testFamilies() []*dto.MetricFamily {
+	return []*dto.MetricFamily{
+		{
+			Name: proto.String(scrapeSuccessMetric),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				gauge(1, "device", "core1"),
+				gauge(1, "device", "core2"),
+				gauge(0, "device", "edge1"),
+			},
+		},
+		{
+			Name: proto.String(neighborInfoMetric),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				neighbor("core1", "10.0.0.1", "sfp1", "core2", "10.0.0.2", "sfp2", "mndp,lldp"),
+				neighbor("core1", "10.0.0.1", "ether5", "printer", "10.0.0.99", "", "lldp"),
+				// configured under a different name, matched by address
+				neighbor("core2", "10.0.0.2", "sfp2", "CORE-1", "10.0.0.1", "", "mndp"),
+				neighbor("core2", "10.0.0.2", "ether1", "edge1", "", "ether1", "cdp"),
+				// the same link reported by a second entry on the same side
+				neighbor("core2", "10.0.0.2", "ether1", "edge1", "10.0.0.3", "", "lldp"),
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testFamilies())
+
+	assert.Equal(t, []Node{
+		{Name: "core1", Address: "10.0.0.1", Up: true},
+		{Name: "core2", Address: "10.0.0.2", Up: true},
+		{Name: "edge1", Up: false},
+	}, g.Nodes)
+
+	assert.Equal(t, []Edge{
+		{Source: "core1", SourceInterface: "sfp1", Target: "core2", TargetInterface: "sfp2", Protocols: []string{"lldp", "mndp"}},
+		{Source: "core2", SourceInterface: "ether1", Target: "edge1", TargetInterface: "ether1", Protocols: []string{"cdp", "lldp"}},
+	}, g.Edges)
+}
+
+func TestWriteDOT(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := Build(testFamilies()).WriteDOT(b)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `graph mikrotik {
+  "core1" [label="core1\n10.0.0.1"];
+  "core2" [label="core2\n10.0.0.2"];
+  "edge1" [label="edge1", color=red];
+  "core1" -- "core2" [taillabel="sfp1", headlabel="sfp2"];
+  "core2" -- "edge1" [taillabel="ether1", headlabel="ether1"];
+}
+`, b.String())
+}
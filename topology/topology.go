@@ -0,0 +1,115 @@
+package topology
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"mikrotik-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is the refresh interval of the graph if none is configured
+const DefaultInterval = time.Minute
+
+// Handler serves the topology as JSON or, with ?format=dot, as Graphviz DOT.
+// Requests are served the last graph built in the background, so they never
+// cause the devices to be scraped.
+type Handler struct {
+	cfg      config.Topology
+	gatherer prometheus.Gatherer
+
+	// refreshMu serializes building the graph
+	refreshMu sync.Mutex
+
+	mu    sync.Mutex
+	graph *Graph
+}
+
+// New creates a topology handler for the given configuration
+func New(cfg config.Topology, g prometheus.Gatherer) *Handler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+
+	return &Handler{
+		cfg:      cfg,
+		gatherer: g,
+	}
+}
+
+// Run rebuilds the graph every refresh interval. It never returns.
+func (h *Handler) Run() {
+	log.WithField("interval", h.cfg.Interval).Info("starting topology refresh")
+
+	h.refresh()
+
+	t := time.NewTicker(h.cfg.Interval)
+	defer t.Stop()
+	for range t.C {
+		h.refresh()
+	}
+}
+
+func (h *Handler) refresh() *Graph {
+	h.refreshMu.Lock()
+	defer h.refreshMu.Unlock()
+
+	mfs, err := h.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect along with the error
+		log.WithField("error", err).Warn("error gathering metrics for topology")
+	}
+
+	g := Build(mfs)
+
+	h.mu.Lock()
+	h.graph = g
+	h.mu.Unlock()
+
+	return g
+}
+
+func (h *Handler) current() *Graph {
+	h.mu.Lock()
+	g := h.graph
+	h.mu.Unlock()
+
+	if g != nil {
+		return g
+	}
+
+	// requests before the first refresh wait for it instead of starting
+	// another one
+	h.refreshMu.Lock()
+	h.mu.Lock()
+	g = h.graph
+	h.mu.Unlock()
+	h.refreshMu.Unlock()
+
+	if g == nil {
+		return h.refresh()
+	}
+
+	return g
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g := h.current()
+
+	var err error
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		err = g.WriteDOT(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(g)
+	}
+	if err != nil {
+		log.WithField("error", err).Error("error writing topology")
+	}
+}